@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// actionsCmd represents the actions command
+var actionsCmd = &cobra.Command{
+	Use:               "actions <pipeline>",
+	Short:             "Show every action execution in a pipeline execution.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePipelineNameArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		executionId, err := cmd.Flags().GetString("execution-id")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		return showActions(cmd.Context(), args[0], executionId, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(actionsCmd)
+
+	actionsCmd.Flags().String("execution-id", "", "Show the actions of this pipeline execution instead of the latest one.")
+}
+
+// Shows the action executions of a pipeline execution in the order they started.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelineExecutions (without --execution-id)
+// 2. ListActionExecutions
+func showActions(ctx context.Context, pipelineName string, executionId string, output string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if executionId == "" {
+		latest, err := awsutil.GetLatestPipelineExecution(ctx, cp, pipelineName)
+		if err != nil {
+			return err
+		}
+		if latest == nil {
+			return fmt.Errorf("pipeline %s has never been executed", pipelineName)
+		}
+		executionId = *latest.PipelineExecutionId
+	}
+
+	details, err := awsutil.GetActionExecutions(ctx, cp, pipelineName, executionId)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(details, func(i, j int) bool {
+		return aws.ToTime(details[i].StartTime).Before(aws.ToTime(details[j].StartTime))
+	})
+
+	if output == "table" {
+		fmt.Printf("Execution %s of %s\n\n", executionId, pipelineName)
+	}
+
+	var rows [][]string
+	for _, detail := range details {
+		var provider, inputArtifacts, configuration string
+		if detail.Input != nil {
+			if detail.Input.ActionTypeId != nil {
+				provider = aws.ToString(detail.Input.ActionTypeId.Provider)
+			}
+			inputArtifacts = artifactNames(detail.Input.InputArtifacts)
+			configuration = formatConfiguration(detail.Input.ResolvedConfiguration)
+			if configuration == "" {
+				configuration = formatConfiguration(detail.Input.Configuration)
+			}
+		}
+
+		var outputArtifacts, errorDetails, url string
+		if detail.Output != nil {
+			outputArtifacts = artifactNames(detail.Output.OutputArtifacts)
+			if result := detail.Output.ExecutionResult; result != nil {
+				url = aws.ToString(result.ExternalExecutionUrl)
+				if result.ErrorDetails != nil {
+					errorDetails = aws.ToString(result.ErrorDetails.Code) + ": " + aws.ToString(result.ErrorDetails.Message)
+				}
+			}
+		}
+
+		started := "-"
+		if detail.StartTime != nil {
+			started = helpers.FormatTime(*detail.StartTime, output)
+		} else if output != "table" {
+			started = ""
+		}
+
+		rows = append(rows, []string{
+			aws.ToString(detail.StageName),
+			aws.ToString(detail.ActionName),
+			provider,
+			string(detail.Status),
+			started,
+			actionDuration(detail, output),
+			inputArtifacts,
+			outputArtifacts,
+			configuration,
+			errorDetails,
+			url,
+		})
+	}
+
+	// Durations are whole seconds outside of the table, so they can be parsed
+	durationHeader := "Duration"
+	if output != "table" {
+		durationHeader = "Duration Seconds"
+	}
+
+	return helpers.RenderOutput(output, []string{"Stage", "Action", "Provider", "Status", "Started", durationHeader, "Input Artifacts", "Output Artifacts", "Configuration", "Error", "URL"}, rows)
+}
+
+// Returns how long an action ran for, or has been running for so far. Outside
+// of the table it is a number of seconds, and empty if the action hasn't started.
+func actionDuration(detail types.ActionExecutionDetail, output string) string {
+	if detail.StartTime == nil {
+		if output != "table" {
+			return ""
+		}
+		return "-"
+	}
+
+	end := time.Now()
+	if detail.Status != types.ActionExecutionStatusInProgress && detail.LastUpdateTime != nil {
+		end = *detail.LastUpdateTime
+	}
+	elapsed := end.Sub(*detail.StartTime).Round(time.Second)
+	if output != "table" {
+		return strconv.Itoa(int(elapsed.Seconds()))
+	}
+	duration := elapsed.String()
+	if detail.Status == types.ActionExecutionStatusInProgress {
+		duration += " (running)"
+	}
+
+	return duration
+}
+
+func artifactNames(artifacts []types.ArtifactDetail) string {
+	var names []string
+	for _, artifact := range artifacts {
+		names = append(names, aws.ToString(artifact.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Formats an action's configuration as key=value pairs, sorted by key
+func formatConfiguration(configuration map[string]string) string {
+	var pairs []string
+	for key, value := range configuration {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
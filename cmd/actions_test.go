@@ -0,0 +1,13 @@
+package cmd
+
+import "testing"
+
+func TestActions(t *testing.T) {
+	out := runCph(t, "actions", "", "actions", "alpha")
+	assertGolden(t, "actions", out)
+}
+
+func TestActionsJSON(t *testing.T) {
+	out := runCph(t, "actions_json", "", "actions", "alpha", "--execution-id", "exec-alpha", "--output", "json")
+	assertGolden(t, "actions_json", out)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/definition"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create or update a pipeline from a definition file.",
+	Long: `Create or update a pipeline from a definition file written by cph export or
+the AWS CLI. The differences from the live definition are shown before
+anything is changed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+
+		return applyDefinition(cmd.Context(), file)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringP("file", "f", "", "Definition file to apply.")
+	applyCmd.MarkFlagRequired("file")
+	applyCmd.MarkFlagFilename("file", "yaml", "yml", "json")
+}
+
+// Core logic for the apply feature.
+// Makes the following calls to CodePipeline:
+// 1. GetPipeline
+// 2. UpdatePipeline, or CreatePipeline if the pipeline does not exist yet
+func applyDefinition(ctx context.Context, file string) error {
+	decl, err := definition.Load(file)
+	if err != nil {
+		return err
+	}
+	name := aws.ToString(decl.Name)
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	live, err := awsutil.GetPipelineDefinition(ctx, cp, name, 0)
+	if err != nil {
+		return err
+	}
+
+	verb := "update"
+	if live == nil {
+		verb = "create"
+		fmt.Printf("\nPipeline %s does not exist yet and will be created.\n", name)
+	} else {
+		changes := definition.Diff(definition.ToMap(live), definition.ToMap(decl))
+		if len(changes) == 0 {
+			fmt.Printf("%s is up to date.\n", name)
+			return nil
+		}
+
+		fmt.Printf("\nThe following changes will be made to %s:\n", name)
+		for _, change := range changes {
+			fmt.Println("    " + formatChange(change))
+		}
+	}
+
+	s, err := helpers.PromptInput(ctx, fmt.Sprintf("\nDo you want to %s %s? Enter 'yes' to continue: ", verb, name))
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(s, "yes") {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	if live == nil {
+		err = awsutil.CreatePipeline(ctx, cp, decl)
+	} else {
+		err = awsutil.UpdatePipeline(ctx, cp, decl)
+	}
+	auditAction(ctx, "apply", name, "", err)
+	if err != nil {
+		return err
+	}
+
+	if live == nil {
+		fmt.Printf("Created %s\n", name)
+	} else {
+		fmt.Printf("Updated %s\n", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import "testing"
+
+func TestApply(t *testing.T) {
+	out := runCph(t, "apply", "yes\n", "apply", "-f", "testdata/definitions/alpha.yaml")
+	assertGolden(t, "apply", out)
+}
+
+func TestApplyUpToDate(t *testing.T) {
+	out := runCph(t, "definition", "", "apply", "-f", "testdata/golden/export_alpha_json.golden")
+	assertGolden(t, "apply_up_to_date", out)
+}
+
+func TestApplyCreate(t *testing.T) {
+	out := runCph(t, "apply_create", "yes\n", "apply", "-f", "testdata/definitions/gamma.json")
+	assertGolden(t, "apply_create", out)
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// approvalsCmd represents the approvals command
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List the manual approvals pipelines are waiting for.",
+	Long: `List every manual approval waiting for a result, with how long it has been
+waiting and the source revision it would let through. Nothing is approved or
+rejected, use cph approve for that.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		sortOrder, err := cmd.Flags().GetString("sort")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if sortOrder != "oldest" && sortOrder != "newest" {
+			return fmt.Errorf("unsupported sort order %q, expected oldest or newest", sortOrder)
+		}
+
+		return listApprovals(cmd.Context(), name, sortOrder, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(approvalsCmd)
+
+	approvalsCmd.Flags().String("name", "", "Use a name or part of a name to filter the pipelines.")
+	approvalsCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	approvalsCmd.Flags().String("sort", "oldest", "Order of the approvals by how long they have been waiting: oldest or newest first.")
+	approvalsCmd.RegisterFlagCompletionFunc("sort", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"oldest", "newest"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// Returns the current time, replaced in tests so ages don't change
+var clock = time.Now
+
+// A pending approval and the execution waiting on it
+type queuedApproval struct {
+	Pipeline  string
+	Approval  awsutil.PendingApproval
+	Execution *types.PipelineExecution
+}
+
+// Core logic for the approvals feature.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines
+// 2. GetPipelineState for each pipeline
+// 3. GetPipelineExecution for each pending approval
+func listApprovals(ctx context.Context, searchTerm string, sortOrder string, output string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
+	if err != nil {
+		return err
+	}
+
+	queue, err := getQueuedApprovals(ctx, cp, pipelineNames)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		if sortOrder == "newest" {
+			return queue[i].Approval.Since.After(queue[j].Approval.Since)
+		}
+		return queue[i].Approval.Since.Before(queue[j].Approval.Since)
+	})
+
+	if output == "table" && len(queue) == 0 {
+		fmt.Println("No pending approvals.")
+		return nil
+	}
+
+	now := clock()
+	var rows [][]string
+	for _, queued := range queue {
+		revision, message, requestedBy := "-", "-", "-"
+		if queued.Execution != nil {
+			if len(queued.Execution.ArtifactRevisions) > 0 {
+				source := queued.Execution.ArtifactRevisions[0]
+				if source.RevisionId != nil {
+					revision = aws.ToString(source.RevisionId)
+					if output == "table" {
+						revision = shortRevision(revision)
+					}
+				}
+				if source.RevisionSummary != nil {
+					message = commitMessage(aws.ToString(source.RevisionSummary))
+				}
+			}
+			if trigger := queued.Execution.Trigger; trigger != nil {
+				requestedBy = string(trigger.TriggerType)
+				if trigger.TriggerDetail != nil {
+					requestedBy = aws.ToString(trigger.TriggerDetail)
+				}
+			}
+		}
+
+		rows = append(rows, []string{
+			queued.Pipeline,
+			queued.Approval.StageName,
+			queued.Approval.ActionName,
+			helpers.FormatTime(queued.Approval.Since, output),
+			formatAge(now.Sub(queued.Approval.Since)),
+			revision,
+			message,
+			requestedBy,
+		})
+	}
+
+	return helpers.RenderOutput(output, []string{"Pipeline", "Stage", "Action", "Waiting Since", "Age", "Revision", "Commit Message", "Requested By"}, rows)
+}
+
+// Returns the pending approvals of the pipelines, along with the executions
+// waiting on them
+func getQueuedApprovals(ctx context.Context, cp *codepipeline.Client, pipelineNames []string) ([]queuedApproval, error) {
+	var queue []queuedApproval
+	for _, name := range pipelineNames {
+		approvals, err := awsutil.GetPendingApprovals(ctx, cp, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, approval := range approvals {
+			queued := queuedApproval{Pipeline: name, Approval: approval}
+			if approval.ExecutionId != "" {
+				queued.Execution, err = awsutil.GetPipelineExecution(ctx, cp, name, approval.ExecutionId)
+				if err != nil {
+					return nil, err
+				}
+			}
+			queue = append(queue, queued)
+		}
+	}
+
+	return queue, nil
+}
+
+// Shortens a commit ID to the length git shows by default. Other revisions,
+// e.g. S3 object versions, are left as they are.
+func shortRevision(revision string) string {
+	if len(revision) == 40 && strings.Trim(strings.ToLower(revision), "0123456789abcdef") == "" {
+		return revision[:7]
+	}
+	return revision
+}
+
+// Returns the first line of the commit message in a revision summary. Sources
+// using a connection, e.g. GitHub, give a JSON summary holding the message.
+func commitMessage(summary string) string {
+	var connection struct {
+		CommitMessage string
+	}
+	if err := json.Unmarshal([]byte(summary), &connection); err == nil && connection.CommitMessage != "" {
+		summary = connection.CommitMessage
+	}
+
+	return strings.TrimSpace(strings.SplitN(summary, "\n", 2)[0])
+}
+
+// Formats how long something has been waiting, to the minute, e.g. 2d 3h or 45m
+func formatAge(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%vd %vh", int(days), int(hours))
+	case hours > 0:
+		return fmt.Sprintf("%vh %vm", int(hours), int(minutes))
+	default:
+		return fmt.Sprintf("%vm", int(minutes))
+	}
+}
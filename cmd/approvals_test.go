@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+// Fixes the time ages are measured from, an hour after the alpha approval started waiting
+func fixClock(t *testing.T) {
+	clock = func() time.Time { return time.Unix(1700003700, 0) }
+	t.Cleanup(func() { clock = time.Now })
+}
+
+func TestApprovals(t *testing.T) {
+	fixClock(t)
+	out := runCph(t, "approvals", "", "approvals")
+	assertGolden(t, "approvals", out)
+}
+
+func TestApprovalsNewestJSON(t *testing.T) {
+	fixClock(t)
+	out := runCph(t, "approvals", "", "approvals", "--sort", "newest", "--output", "json")
+	assertGolden(t, "approvals_newest_json", out)
+}
+
+func TestApprovalsNone(t *testing.T) {
+	out := runCph(t, "approvals_none", "", "approvals", "--name", "gamma")
+	assertGolden(t, "approvals_none", out)
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{90 * time.Second, "1m"},
+		{2*time.Hour + 5*time.Minute, "2h 5m"},
+		{50 * time.Hour, "2d 2h"},
+	}
+	for _, test := range tests {
+		if got := formatAge(test.age); got != test.want {
+			t.Errorf("formatAge(%v) = %q, want %q", test.age, got, test.want)
+		}
+	}
+}
@@ -1,31 +1,54 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
 	"github.com/spf13/cobra"
 
+	"github.com/shreyasrama/cph/pkg/audit"
 	"github.com/shreyasrama/cph/pkg/awsutil"
 	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/policy"
 )
 
 // approveCmd represents the approve command
 var approveCmd = &cobra.Command{
-	Use:   "approve",
-	Short: "Approve CodePipelines based on a provided search term.",
+	Use:          "approve",
+	Short:        "Approve CodePipelines based on a provided search term.",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name, err := cmd.Flags().GetString("name")
 		if err != nil {
 			return err
 		}
+		opts, err := getPolicyOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		var filter approvalFilter
+		filter.Pipeline, err = cmd.Flags().GetString("pipeline")
+		if err != nil {
+			return err
+		}
+		filter.ExecutionId, err = cmd.Flags().GetString("execution-id")
+		if err != nil {
+			return err
+		}
+		filter.Revision, err = cmd.Flags().GetString("revision")
+		if err != nil {
+			return err
+		}
 
-		return approvePipelines(name)
+		if filter.isSet() {
+			return approveMatching(cmd.Context(), name, filter, opts)
+		}
+		return approvePipelines(cmd.Context(), name, opts)
 	},
 }
 
@@ -37,6 +60,14 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	approveCmd.PersistentFlags().String("name", "", "Use a name or part of a name to filter the runnable pipelines.")
+	approveCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	approveCmd.PersistentFlags().String("pipeline", "", "Approve the pipeline with this exact name, without choosing it from a list.")
+	approveCmd.RegisterFlagCompletionFunc("pipeline", completePipelineNames)
+	approveCmd.PersistentFlags().String("execution-id", "", "Approve the approval waiting on this execution, without choosing it from a list.")
+	approveCmd.PersistentFlags().String("revision", "", "Approve the approvals waiting on executions of this source revision, e.g. a commit ID or its first few characters.")
+	approveCmd.PersistentFlags().String("reason", "", "Why the pipelines are being approved or rejected, recorded in the audit log.")
+	approveCmd.PersistentFlags().Bool("override", false, "Approve even though the policy denies it. Needs a --reason, and is recorded in the audit log.")
+	approveCmd.PersistentFlags().Bool(protectedBatchFlag, false, "Let 'yes' include protected pipelines, which still have to be confirmed.")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -47,29 +78,47 @@ func init() {
 // Core logic for the approve feature.
 // Notable data structures/variables:
 // pipelineNames []string - names of the pipeline that the search returned.
-// pipelineMap (map[int]string) - maps the number the pipeline corresponds to in the search results to its name.
-// executionTable (var) - table that presents the output from the run command.
-func approvePipelines(searchTerm string) error {
-	cp, err := awsutil.CreateCodePipelineSession()
+// stagesToApprove (map[string]queuedApproval) - maps the pipelines waiting for approval to their first pending approval.
+func approvePipelines(ctx context.Context, searchTerm string, opts policyOptions) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+	p, err := loadPolicy()
 	if err != nil {
 		return err
 	}
 
-	pipelineNames, err := awsutil.GetPipelineNames(cp, searchTerm)
+	// Checks the chosen approvals against the policy, and has protected
+	// pipelines confirmed, before approving them
+	allowed := func(approvals map[string]queuedApproval, batch bool) (audit.Record, bool, error) {
+		targets, err := approvalTargets(ctx, cp, p, approvals)
+		if err != nil {
+			return audit.Record{}, false, err
+		}
+		details, err := enforcePolicy(ctx, p, policy.Approve, targets, opts)
+		if err != nil {
+			return details, false, err
+		}
+		ok, err := confirmProtected(ctx, cp, policy.Approve, sortedPipelines(approvals), batch, opts.AllowProtectedBatch)
+		return details, ok, err
+	}
+
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
 	if err != nil {
 		return err
 	}
 
-	// Iterate over pipeline names and get the most recent pipeline
-	// execution status and create a map of names to StageInfo
-	stagesToApprove := make(map[string]awsutil.StageInfo)
+	// Iterate over pipeline names and create a map of names to the first
+	// approval each pipeline is waiting for
+	stagesToApprove := make(map[string]queuedApproval)
 	for _, name := range pipelineNames {
-		stageInfo, err := awsutil.GetLastExecutedStage(cp, name)
+		approvals, err := awsutil.GetPendingApprovals(ctx, cp, name)
 		if err != nil {
 			return err
 		}
-		if stageInfo.Status == "InProgress" {
-			stagesToApprove[name] = stageInfo
+		if len(approvals) > 0 {
+			stagesToApprove[name] = queuedApproval{Pipeline: name, Approval: approvals[0]}
 		}
 	}
 
@@ -79,112 +128,279 @@ func approvePipelines(searchTerm string) error {
 	}
 
 	// Print and confirm pipelines to be approved
-	pipelineMap := make(map[int]string)
+	names := sortedPipelines(stagesToApprove)
 	fmt.Printf("\n%s\n", "The following pipelines have been found:")
-	i := 0
-	// TODO: executionTable?
-	for pipeline := range stagesToApprove {
-		pipelineMap[i+1] = pipeline
-		fmt.Printf("    [%v] %s (%s)\n", i+1, pipeline, stagesToApprove[pipeline].StageName)
-		i++
-	}
-
-	var s string
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Printf(
-		"\n%s",
-		`Do you want to approve these pipelines?
-Enter 'yes' to approve all, 'no' to cancel, 'reject' to reject all, a number for a specific pipeline, or provide a range or list: `)
-	if scanner.Scan() {
-		s = scanner.Text()
-	}
-
-	if i, err := strconv.Atoi(s); err == nil { // User enters a single number
-		stageToApprove := make(map[string]awsutil.StageInfo)
-		stageToApprove[pipelineMap[i]] = stagesToApprove[pipelineMap[i]]
-		err := awsutil.ApprovePipelines(cp, stageToApprove, codepipeline.ApprovalStatusApproved)
+	for i, pipeline := range names {
+		fmt.Printf("    [%v] %s (%s)\n", i+1, pipeline, stagesToApprove[pipeline].Approval.StageName)
+	}
+
+	chosen, err := promptPipelineSelection(ctx, names, "approve", true)
+	if err != nil || len(chosen.Names) == 0 {
+		return err
+	}
+	approvals := make(map[string]queuedApproval)
+	for _, name := range chosen.Names {
+		approvals[name] = stagesToApprove[name]
+	}
+
+	if chosen.Reject {
+		fmt.Println("Rejecting pipelines...")
+		// Rejecting is always allowed, as it can't let a change through
+		err := approvePipelinesAudited(ctx, cp, approvals, types.ApprovalStatusRejected, audit.Record{Reason: opts.Reason})
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Approved %s\n", pipelineMap[i])
+		for _, name := range chosen.Names {
+			fmt.Printf("Rejected %s\n", name)
+		}
+		return nil
+	}
 
-	} else if strings.EqualFold(s, "yes") {
+	details, ok, err := allowed(approvals, chosen.All)
+	if err != nil || !ok {
+		return err
+	}
+	if len(chosen.Names) > 1 || chosen.All {
 		fmt.Println("Approving pipelines...")
-		err := awsutil.ApprovePipelines(cp, stagesToApprove, codepipeline.ApprovalStatusApproved)
-		if err != nil {
-			return err
-		}
+	}
+	err = approvePipelinesAudited(ctx, cp, approvals, types.ApprovalStatusApproved, details)
+	if err != nil {
+		return err
+	}
+	for _, name := range chosen.Names {
+		fmt.Printf("Approved %s\n", name)
+	}
 
-		for name := range stagesToApprove {
-			fmt.Printf("Approved %s\n", name)
-		}
+	return nil
+}
 
-	} else if strings.EqualFold(s, "no") {
-		fmt.Println("Cancelled.")
-		// exit?
+// Returns the names of the pipelines with approvals, sorted
+func sortedPipelines(approvals map[string]queuedApproval) []string {
+	names := make([]string, 0, len(approvals))
+	for name := range approvals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	} else if strings.EqualFold(s, "reject") {
-		fmt.Println("Rejecting pipelines...")
-		err := awsutil.ApprovePipelines(cp, stagesToApprove, codepipeline.ApprovalStatusRejected)
-		if err != nil {
-			return err
-		}
+	return names
+}
 
-		for name := range stagesToApprove {
-			fmt.Printf("Rejected %s\n", name)
+// Chooses pending approvals by what they would let through, rather than by
+// their number in a list
+type approvalFilter struct {
+	// Exact pipeline name
+	Pipeline    string
+	ExecutionId string
+	// Prefix of a source revision ID, e.g. a commit ID
+	Revision string
+}
+
+// Shortest revision prefix accepted, the same as git's
+const minRevisionPrefix = 4
+
+func (f approvalFilter) isSet() bool {
+	return f.Pipeline != "" || f.ExecutionId != "" || f.Revision != ""
+}
+
+// Describes the filter, e.g. "pipeline alpha and revision 0123abc"
+func (f approvalFilter) String() string {
+	var parts []string
+	if f.Pipeline != "" {
+		parts = append(parts, "pipeline "+f.Pipeline)
+	}
+	if f.ExecutionId != "" {
+		parts = append(parts, "execution "+f.ExecutionId)
+	}
+	if f.Revision != "" {
+		parts = append(parts, "revision "+f.Revision)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Returns the ID of the execution's source revision starting with the
+// filter's revision, or an empty string if it has none
+func (f approvalFilter) matchingRevision(execution *types.PipelineExecution) string {
+	if execution == nil {
+		return ""
+	}
+	for _, revision := range execution.ArtifactRevisions {
+		id := aws.ToString(revision.RevisionId)
+		if strings.HasPrefix(strings.ToLower(id), strings.ToLower(f.Revision)) {
+			return id
 		}
-	} else { // User enters a range
-		rangeMatch, _ := regexp.MatchString("^[0-9]{1,2}-[0-9]{1,2}$", s) // e.g. 1-3, 2-6
-		selectionMatch, _ := regexp.MatchString(`(\d+)(,\s*\d+)*`, s)     // e.g. 1,3,5
+	}
+	return ""
+}
+
+// Core logic for approving by pipeline name, execution ID or revision.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines
+// 2. GetPipelineState for each pipeline and GetPipelineExecution for each pending approval
+// 3. GetPipelineState for each match, to check it still waits on the same execution
+// 4. PutApprovalResult for each match
+func approveMatching(ctx context.Context, searchTerm string, filter approvalFilter, opts policyOptions) error {
+	if filter.Revision != "" && len(filter.Revision) < minRevisionPrefix {
+		return fmt.Errorf("--revision needs at least %v characters", minRevisionPrefix)
+	}
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+	p, err := loadPolicy()
+	if err != nil {
+		return err
+	}
 
-		if rangeMatch {
-			pipelinesToApprove, err := helpers.ProcessInputRange(s, len(pipelineNames))
-			if err != nil {
-				return err
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
+	if err != nil {
+		return err
+	}
+	if filter.Pipeline != "" {
+		var exact []string
+		for _, name := range pipelineNames {
+			if name == filter.Pipeline {
+				exact = append(exact, name)
 			}
+		}
+		pipelineNames = exact
+	}
 
-			// Approve pipelines
-			approveStages := make(map[string]awsutil.StageInfo)
-			for i := range pipelinesToApprove {
-				approveStages[pipelineMap[pipelinesToApprove[i]]] = stagesToApprove[pipelineMap[pipelinesToApprove[i]]]
+	queue, err := getQueuedApprovals(ctx, cp, pipelineNames)
+	if err != nil {
+		return err
+	}
+	var matches []queuedApproval
+	revisions := make(map[string]bool)
+	for _, queued := range queue {
+		if filter.ExecutionId != "" && queued.Approval.ExecutionId != filter.ExecutionId {
+			continue
+		}
+		if filter.Revision != "" {
+			revision := filter.matchingRevision(queued.Execution)
+			if revision == "" {
+				continue
 			}
+			revisions[revision] = true
+		}
+		matches = append(matches, queued)
+	}
 
-			approveMultiInputPipelines(cp, approveStages, pipelineMap)
+	if len(matches) == 0 {
+		return fmt.Errorf("no pending approval matches %s", filter)
+	}
+	if len(revisions) > 1 {
+		var ids []string
+		for id := range revisions {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return fmt.Errorf("revision %s is ambiguous, it could be any of %s", filter.Revision, strings.Join(ids, ", "))
+	}
 
-		} else if selectionMatch {
-			pipelinesToApprove, err := helpers.ProcessInputSelection(s, len(pipelineNames))
-			if err != nil {
-				return err
-			}
+	// Print and confirm the approvals
+	fmt.Printf("\n%s\n", "The following approvals match:")
+	for i, m := range matches {
+		fmt.Printf("    [%v] %s (%s), execution %s, revision %s\n", i+1, m.Pipeline, m.Approval.StageName, m.Approval.ExecutionId, describeRevision(m.Execution))
+	}
 
-			// Approve pipelines
-			approveStages := make(map[string]awsutil.StageInfo)
-			for i := range pipelinesToApprove {
-				approveStages[pipelineMap[pipelinesToApprove[i]]] = stagesToApprove[pipelineMap[pipelinesToApprove[i]]]
-			}
+	s, err := helpers.PromptInput(ctx, "\nDo you want to approve these? Enter 'yes' to approve, 'no' to cancel or 'reject' to reject: ")
+	if err != nil {
+		return err
+	}
 
-			approveMultiInputPipelines(cp, approveStages, pipelineMap)
+	status, command := types.ApprovalStatusApproved, "approve"
+	details := audit.Record{Reason: opts.Reason}
+	if strings.EqualFold(s, "yes") {
+		var targets []policy.Target
+		for _, m := range matches {
+			target := policy.Target{Pipeline: m.Pipeline}
+			if p != nil && p.DenySelfApproval {
+				if target, err = approvalTarget(ctx, cp, m.Pipeline, m.Execution); err != nil {
+					return err
+				}
+			}
+			targets = append(targets, target)
+		}
+		details, err = enforcePolicy(ctx, p, policy.Approve, targets, opts)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Pipeline)
+		}
+		if ok, err := confirmProtected(ctx, cp, policy.Approve, names, false, opts.AllowProtectedBatch); err != nil || !ok {
+			return err
+		}
+	} else if strings.EqualFold(s, "reject") {
+		status, command = types.ApprovalStatusRejected, "reject"
+	} else {
+		fmt.Println("Cancelled.")
+		return nil
+	}
 
-		} else {
-			fmt.Println("Input not recognised.")
-			// exit?
+	approver, err := awsutil.GetCallerArn(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		info, err := currentApproval(ctx, cp, m)
+		if err != nil {
+			return err
+		}
+		err = awsutil.ApprovePipeline(ctx, cp, m.Pipeline, info, status, approver)
+		record := details
+		record.Command, record.Pipeline, record.Stage, record.ExecutionId = command, m.Pipeline, m.Approval.StageName, m.Approval.ExecutionId
+		auditRecord(ctx, record, err)
+		if err != nil {
+			return err
 		}
+		fmt.Printf("%s %s (execution %s)\n", string(status), m.Pipeline, m.Approval.ExecutionId)
 	}
 
 	return nil
 }
 
-// For range and selection inputs.
-// Takes map of pipeline names -> their approval stage to approve the appropriate pipelines
-func approveMultiInputPipelines(cp *codepipeline.CodePipeline, stagesToApprove map[string]awsutil.StageInfo, pipelineMap map[int]string) error {
-	fmt.Println("Approving pipelines...")
-	err := awsutil.ApprovePipelines(cp, stagesToApprove, codepipeline.ApprovalStatusApproved)
+// Returns the stage info to approve a matched approval with, after checking
+// it is still waiting on the same execution. Its token would otherwise let a
+// different change through than the one that was confirmed.
+func currentApproval(ctx context.Context, cp *codepipeline.Client, m queuedApproval) (awsutil.StageInfo, error) {
+	approvals, err := awsutil.GetPendingApprovals(ctx, cp, m.Pipeline)
 	if err != nil {
-		return err
+		return awsutil.StageInfo{}, err
 	}
-	for name := range stagesToApprove {
-		fmt.Printf("Approved %s\n", name)
+
+	for _, approval := range approvals {
+		if approval.StageName != m.Approval.StageName || approval.ActionName != m.Approval.ActionName {
+			continue
+		}
+		if approval.ExecutionId != m.Approval.ExecutionId {
+			return awsutil.StageInfo{}, fmt.Errorf("%s (%s) is now waiting on execution %s rather than %s, run the command again", m.Pipeline, m.Approval.StageName, approval.ExecutionId, m.Approval.ExecutionId)
+		}
+		if approval.Token != m.Approval.Token {
+			return awsutil.StageInfo{}, fmt.Errorf("the approval of %s (%s) has restarted since it was listed, run the command again", m.Pipeline, m.Approval.StageName)
+		}
+		return awsutil.StageInfo{
+			ActionName: approval.ActionName,
+			StageName:  approval.StageName,
+			Status:     "InProgress",
+			Token:      aws.String(approval.Token),
+		}, nil
 	}
 
-	return nil
+	return awsutil.StageInfo{}, fmt.Errorf("%s is no longer waiting for approval in %s", m.Pipeline, m.Approval.StageName)
+}
+
+// Describes an execution's first source revision, e.g. "0123abc (Fix login bug)"
+func describeRevision(execution *types.PipelineExecution) string {
+	if execution == nil || len(execution.ArtifactRevisions) == 0 || execution.ArtifactRevisions[0].RevisionId == nil {
+		return noRevision
+	}
+	source := execution.ArtifactRevisions[0]
+	revision := shortRevision(aws.ToString(source.RevisionId))
+	if source.RevisionSummary != nil {
+		revision += " (" + commitMessage(aws.ToString(source.RevisionSummary)) + ")"
+	}
+	return revision
 }
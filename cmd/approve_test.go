@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+)
+
+func TestApprove(t *testing.T) {
+	out := runCph(t, "approve", "yes\n", "approve")
+	assertGolden(t, "approve", out)
+}
+
+func TestApproveReject(t *testing.T) {
+	out := runCph(t, "approve_reject", "reject\n", "approve")
+	assertGolden(t, "approve_reject", out)
+}
+
+func TestApproveNothingPending(t *testing.T) {
+	out := runCph(t, "approve_nothing_pending", "", "approve", "--name", "alpha")
+	assertGolden(t, "approve_nothing_pending", out)
+}
+
+func TestApproveSorted(t *testing.T) {
+	out := runCph(t, "approve_sorted", "2\n", "approve")
+	assertGolden(t, "approve_sorted", out)
+}
+
+func TestApproveOutOfRange(t *testing.T) {
+	_, err := runCphErr(t, "approve_out_of_range", "3\n", "approve")
+	if err == nil || err.Error() != "3 is not one of the listed pipelines" {
+		t.Errorf("cph approve: %v, want an out of range error", err)
+	}
+}
+
+func TestApproveRevision(t *testing.T) {
+	out := runCph(t, "approve_direct", "yes\n", "approve", "--revision", "0123456")
+	assertGolden(t, "approve_revision", out)
+
+	records, err := audit.Query(time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Pipeline != "alpha" || records[0].ExecutionId != "exec-alpha-1" || records[0].Stage != "Approval" {
+		t.Errorf("unexpected audit records: %+v", records)
+	}
+}
+
+func TestApproveExecutionIdReject(t *testing.T) {
+	out := runCph(t, "approve_execution_id_reject", "reject\n", "approve", "--execution-id", "exec-beta-1")
+	assertGolden(t, "approve_execution_id_reject", out)
+}
+
+func TestApproveNoMatch(t *testing.T) {
+	tests := []struct {
+		fixture string
+		args    []string
+		wantErr string
+	}{
+		{"approve_no_match_pipeline", []string{"--pipeline", "gamma"}, "no pending approval matches pipeline gamma"},
+		{"approve_no_match_prefix", []string{"--pipeline", "alph"}, "no pending approval matches pipeline alph"},
+		{"approve_no_match_execution", []string{"--pipeline", "alpha", "--execution-id", "exec-beta-1"}, "no pending approval matches pipeline alpha and execution exec-beta-1"},
+		{"none", []string{"--revision", "012"}, "--revision needs at least 4 characters"},
+	}
+	for _, test := range tests {
+		_, err := runCphErr(t, test.fixture, "", append([]string{"approve"}, test.args...)...)
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("cph approve %v: %v, want %q", test.args, err, test.wantErr)
+		}
+	}
+}
+
+func TestApproveInteractiveExecutionMoved(t *testing.T) {
+	_, err := runCphErr(t, "approve_interactive_moved", "yes\n", "approve")
+	want := "alpha (Approval) is now waiting on execution exec-alpha-2 rather than exec-alpha-1, run the command again"
+	if err == nil || err.Error() != want {
+		t.Errorf("cph approve: %v, want %q", err, want)
+	}
+}
+
+func TestApproveExecutionMoved(t *testing.T) {
+	_, err := runCphErr(t, "approve_moved", "yes\n", "approve", "--pipeline", "alpha")
+	want := "alpha (Approval) is now waiting on execution exec-alpha-2 rather than exec-alpha-1, run the command again"
+	if err == nil || err.Error() != want {
+		t.Errorf("cph approve: %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the actions cph has performed from this machine.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, err := cmd.Flags().GetString("since")
+		if err != nil {
+			return err
+		}
+		until, err := cmd.Flags().GetString("until")
+		if err != nil {
+			return err
+		}
+		pipeline, err := cmd.Flags().GetString("pipeline")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		return showAuditLog(since, until, pipeline, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().String("since", "", "Only show actions after this time, e.g. 24h, 2006-01-02 or an RFC 3339 timestamp.")
+	auditCmd.Flags().String("until", "", "Only show actions before this time, e.g. 1h, 2006-01-02 or an RFC 3339 timestamp.")
+	auditCmd.Flags().String("pipeline", "", "Only show actions performed on this pipeline.")
+	auditCmd.RegisterFlagCompletionFunc("pipeline", completePipelineNames)
+}
+
+func showAuditLog(since string, until string, pipeline string, output string) error {
+	now := time.Now()
+	sinceTime, err := helpers.ParseTimeFlag(since, now)
+	if err != nil {
+		return err
+	}
+	untilTime, err := helpers.ParseTimeFlag(until, now)
+	if err != nil {
+		return err
+	}
+
+	records, err := audit.Query(sinceTime, untilTime, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, record := range records {
+		rows = append(rows, []string{
+			helpers.FormatTime(record.Timestamp, output),
+			record.Command,
+			record.Pipeline,
+			record.Stage,
+			record.ExecutionId,
+			record.Reason,
+			strings.Join(record.Overrides, "; "),
+			record.Result,
+			record.CallerArn,
+			record.Profile,
+			record.Region,
+		})
+	}
+
+	return helpers.RenderOutput(output, []string{"Time", "Command", "Pipeline", "Stage", "Execution ID", "Reason", "Overrides", "Result", "Caller", "Profile", "Region"}, rows)
+}
+
+// Identity details added to every audit record, looked up once per invocation
+var auditIdentity *audit.Record
+
+// Appends a record of an action to the audit log. Failing to write the audit
+// log is reported but does not fail the command, as the action has already happened.
+// Nothing is recorded in dry-run mode.
+func auditAction(ctx context.Context, command string, pipeline string, executionId string, actionErr error) {
+	auditRecord(ctx, audit.Record{Command: command, Pipeline: pipeline, ExecutionId: executionId}, actionErr)
+}
+
+// Like auditAction, for records that need more than a command, pipeline and
+// execution ID. The timestamp, identity and result are filled in.
+func auditRecord(ctx context.Context, record audit.Record, actionErr error) {
+	if awsutil.DryRunEnabled() {
+		return
+	}
+
+	if auditIdentity == nil {
+		auditIdentity = &audit.Record{Profile: os.Getenv("AWS_PROFILE")}
+		if arn, err := awsutil.GetCallerArn(ctx); err == nil {
+			auditIdentity.CallerArn = arn
+		}
+		if region, err := awsutil.GetRegion(ctx); err == nil {
+			auditIdentity.Region = region
+		}
+	}
+
+	result := "success"
+	if actionErr != nil {
+		result = "error: " + actionErr.Error()
+	}
+
+	record.Timestamp = time.Now().UTC()
+	record.CallerArn = auditIdentity.CallerArn
+	record.Profile = auditIdentity.Profile
+	record.Region = auditIdentity.Region
+	record.Result = result
+
+	err := audit.Append(record)
+	if err != nil {
+		fmt.Println("Error writing audit log: ", err)
+	}
+}
+
+// Runs a pipeline and records it in the audit log, along with the reason and
+// policy overrides in details
+func runPipelineAudited(ctx context.Context, cp *codepipeline.Client, pipelineName string, details audit.Record) (string, error) {
+	executionId, err := awsutil.RunPipeline(ctx, cp, pipelineName)
+	details.Command, details.Pipeline, details.ExecutionId = "run", pipelineName, executionId
+	auditRecord(ctx, details, err)
+
+	return executionId, err
+}
+
+// Runs pipelines and records each of them in the audit log.
+// Returns a map of execution IDs to pipeline names, like awsutil.RunPipelines.
+// On error, the map holds the executions that started before it.
+func runPipelinesAudited(ctx context.Context, cp *codepipeline.Client, pipelineNames []string, details audit.Record) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, name := range pipelineNames {
+		executionId, err := runPipelineAudited(ctx, cp, name, details)
+		if err != nil {
+			return m, err
+		}
+		m[executionId] = name
+	}
+
+	return m, nil
+}
+
+// Puts the approval result for each pipeline's approval and records each of
+// them in the audit log, along with the reason and policy overrides in
+// details. Each approval is checked to still be waiting on the execution it
+// was listed with first.
+func approvePipelinesAudited(ctx context.Context, cp *codepipeline.Client, approvals map[string]queuedApproval, approvalStatus types.ApprovalStatus, details audit.Record) error {
+	approver, err := awsutil.GetCallerArn(ctx)
+	if err != nil {
+		return err
+	}
+
+	command := "approve"
+	if approvalStatus == types.ApprovalStatusRejected {
+		command = "reject"
+	}
+
+	for _, name := range sortedPipelines(approvals) {
+		m := approvals[name]
+		info, err := currentApproval(ctx, cp, m)
+		if err != nil {
+			return err
+		}
+		err = awsutil.ApprovePipeline(ctx, cp, name, info, approvalStatus, approver)
+		record := details
+		record.Command, record.Pipeline, record.Stage, record.ExecutionId = command, name, m.Approval.StageName, m.Approval.ExecutionId
+		auditRecord(ctx, record, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+)
+
+func TestAuditCSV(t *testing.T) {
+	t.Setenv("CPH_AUDIT_LOG", filepath.Join(t.TempDir(), "audit.jsonl"))
+	zone := time.FixedZone("NZDT", 13*60*60)
+	record := audit.Record{Timestamp: time.Date(2023, 11, 15, 11, 15, 0, 0, zone), Command: "approve", Pipeline: "alpha", Result: "Approved"}
+	if err := audit.Append(record); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	out := captureStdout(t, func() {
+		err = showAuditLog("", "", "", "csv")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n2023-11-14T22:15:00Z,approve,alpha,"; !strings.Contains(out, want) {
+		t.Errorf("cph audit --output csv:\n%s\nwant a row starting with %q", out, strings.TrimPrefix(want, "\n"))
+	}
+}
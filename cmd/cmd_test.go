@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/replay"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+var record = flag.Bool("record", false, "record fixtures against AWS, using your credentials, instead of replaying them")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	// Keep output identical on every machine
+	color.NoColor = true
+	time.Local = time.UTC
+
+	os.Exit(m.Run())
+}
+
+// Runs cph with args, answering prompts with input, and returns everything it
+// printed to stdout. AWS calls are replayed from testdata/fixtures/<fixture>.json,
+// or recorded to it when the tests are run with -record.
+func runCph(t *testing.T, fixture string, input string, args ...string) string {
+	t.Helper()
+
+	out, err := runCphErr(t, fixture, input, args...)
+	if err != nil {
+		t.Errorf("cph %s: %v", strings.Join(args, " "), err)
+	}
+
+	return out
+}
+
+// Like runCph, for commands that are expected to fail. Returns the error
+// instead of failing the test.
+func runCphErr(t *testing.T, fixture string, input string, args ...string) (string, error) {
+	t.Helper()
+
+	replayFixture(t, fixture)
+
+	// Keep the tests fast, AWS rate limits are retried anyway. Tests that
+	// need more config use writeConfig first.
+	if os.Getenv("CPH_CONFIG") == "" {
+		writeConfig(t, "")
+	}
+	t.Setenv("CPH_AUDIT_LOG", filepath.Join(t.TempDir(), "audit.jsonl"))
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	helpers.SetInput(strings.NewReader(input))
+
+	// Flag values stick between executions, so put them back to their defaults
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+
+	var err error
+	out := captureStdout(t, func() {
+		err = rootCmd.ExecuteContext(context.Background())
+	})
+
+	return out, err
+}
+
+// Replays AWS calls from testdata/fixtures/<fixture>.json, or records them to
+// it when the tests are run with -record
+func replayFixture(t *testing.T, fixture string) {
+	t.Helper()
+
+	fixturePath := filepath.Join("testdata", "fixtures", fixture+".json")
+	if *record {
+		recorder := replay.NewRecorder()
+		awsutil.SetHTTPClient(recorder)
+		t.Cleanup(func() {
+			if err := recorder.Fixture().Save(fixturePath); err != nil {
+				t.Errorf("saving fixture: %v", err)
+			}
+		})
+	} else {
+		// Make sure nothing from the environment is used while replaying
+		dir := t.TempDir()
+		t.Setenv("AWS_PROFILE", "")
+		t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+		t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIAREPLAY")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "replay")
+		t.Setenv("AWS_SESSION_TOKEN", "")
+		t.Setenv("AWS_REGION", "us-east-1")
+		t.Setenv("AWS_CA_BUNDLE", "")
+		t.Setenv("AWS_ENDPOINT_URL", "")
+
+		f, err := replay.Load(fixturePath)
+		if err != nil {
+			t.Fatalf("loading fixture: %v", err)
+		}
+		replayer := replay.NewReplayer(f)
+		awsutil.SetHTTPClient(replayer)
+		// A call the test expected but cph didn't make is as wrong as an extra one
+		t.Cleanup(func() {
+			for _, interaction := range replayer.Unused() {
+				t.Errorf("fixture %s: %s %s was not called with request %s", fixture, interaction.Service, interaction.Operation, interaction.Request)
+			}
+		})
+	}
+}
+
+// Writes a config file with the given settings, on top of the ones every
+// test uses, and points cph at it
+func writeConfig(t *testing.T, settings string) {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("rateLimit: 0\n"+settings), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CPH_CONFIG", configPath)
+}
+
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// Returns what fn printed to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		output <- buf.String()
+	}()
+
+	fn()
+	w.Close()
+
+	return <-output
+}
+
+// Compares output with testdata/golden/<name>.golden, or rewrites the golden
+// file when the tests are run with -update
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
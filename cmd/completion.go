@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate a shell completion script.",
+	Long: `Generate a shell completion script for cph. Pipeline names are completed
+for --name and other pipeline arguments, using the response cache so that
+completion stays fast.
+
+Bash:
+  source <(cph completion bash)
+
+Zsh:
+  cph completion zsh > "${fpath[1]}/_cph"
+
+Fish:
+  cph completion fish > ~/.config/fish/completions/cph.fish
+
+PowerShell:
+  cph completion powershell | Out-String | Invoke-Expression
+`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		default:
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+// Completes pipeline names for flags and arguments
+func completePipelineNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, err := cachedPipelineNames(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes a pipeline name for commands that take one as their first argument
+func completePipelineNameArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completePipelineNames(cmd, args, toComplete)
+}
+
+// Returns every pipeline name with the settings of the command being completed,
+// which are applied here as __complete doesn't parse flags before the root's
+// PersistentPreRunE. The list is usually answered from awsutil's cache.
+func cachedPipelineNames(cmd *cobra.Command) ([]string, error) {
+	// awsutil prints errors to stdout, which the shell would read as
+	// completions, so send them to stderr while fetching
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	if err := configureAWS(cmd); err != nil {
+		return nil, err
+	}
+	ctx := cmd.Context()
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return awsutil.GetPipelineNames(ctx, cp, "")
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletePipelineNames(t *testing.T) {
+	out := runCph(t, "complete_pipeline_names", "", "__complete", "list", "--name", "al")
+	if !strings.HasPrefix(out, "alpha\n:4\n") {
+		t.Errorf("got completions:\n%s\nwant alpha without file completion", out)
+	}
+}
+
+func TestCompletionAppliesFlags(t *testing.T) {
+	// The invalid flag is only noticed if completion applies the flags of the
+	// command line being completed
+	out := runCph(t, "none", "", "__complete", "list", "--max-attempts", "0", "--name", "al")
+	if !strings.HasPrefix(out, ":1\n") {
+		t.Errorf("got completions:\n%s\nwant an error from --max-attempts", out)
+	}
+}
+
+func TestCompletionArgs(t *testing.T) {
+	if _, err := runCphErr(t, "none", "", "completion", "tcsh"); err == nil {
+		t.Error("cph completion tcsh succeeded, want an invalid argument error")
+	}
+}
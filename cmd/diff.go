@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/definition"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <pipeline> [other-pipeline]",
+	Short: "Show the differences between two pipeline definitions.",
+	Long: `Show the differences between the definitions of two pipelines, or between an
+earlier version of a pipeline and its current version with --version.
+Stages and actions are matched by name, so added, removed and changed actions
+and configuration keys are shown as such.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completePipelineNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := cmd.Flags().GetInt32("version")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 2 && version != 0 {
+			return fmt.Errorf("--version can only be used with a single pipeline")
+		}
+		if len(args) == 1 && version == 0 {
+			return fmt.Errorf("give two pipelines to compare, or one pipeline and --version")
+		}
+
+		return diffPipelines(cmd.Context(), args, version, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().Int32("version", 0, "Compare this version of the pipeline with its current version.")
+}
+
+// Core logic for the diff feature.
+// Makes the following calls to CodePipeline:
+// 1. GetPipeline for each definition compared
+func diffPipelines(ctx context.Context, pipelineNames []string, version int32, output string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	oldName, newName := pipelineNames[0], pipelineNames[0]
+	oldLabel, newLabel := oldName, newName
+	if len(pipelineNames) == 2 {
+		newName, newLabel = pipelineNames[1], pipelineNames[1]
+	} else {
+		oldLabel = fmt.Sprintf("%s version %v", oldName, version)
+		newLabel = newName + " current version"
+	}
+
+	oldDecl, err := getDefinition(ctx, cp, oldName, version)
+	if err != nil {
+		return err
+	}
+	newDecl, err := getDefinition(ctx, cp, newName, 0)
+	if err != nil {
+		return err
+	}
+
+	var changes []definition.Change
+	for _, change := range definition.Diff(definition.ToMap(oldDecl), definition.ToMap(newDecl)) {
+		// Two different pipelines always differ in name, which isn't worth showing
+		if change.Path == "name" && oldName != newName {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	if output == "json" {
+		return printChangesJSON(changes)
+	}
+	if output != "table" {
+		var rows [][]string
+		for _, change := range changes {
+			rows = append(rows, []string{change.Kind(), change.Stage, change.Action, change.Path, formatValue(change.Old), formatValue(change.New)})
+		}
+		return helpers.RenderOutput(output, []string{"Change", "Stage", "Action", "Path", "Old", "New"}, rows)
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("No differences between %s and %s.\n", oldLabel, newLabel)
+		return nil
+	}
+	fmt.Printf("Differences between %s (-) and %s (+):\n", oldLabel, newLabel)
+	for _, change := range changes {
+		fmt.Println("    " + formatChange(change))
+	}
+
+	return nil
+}
+
+// A change as written by --output json, with the old and new values kept as
+// they are in the definition. A value that is absent is null.
+type jsonChange struct {
+	Change string      `json:"change"`
+	Stage  string      `json:"stage"`
+	Action string      `json:"action"`
+	Path   string      `json:"path"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// Writes the changes to stdout as a JSON array
+func printChangesJSON(changes []definition.Change) error {
+	objects := make([]jsonChange, 0, len(changes))
+	for _, change := range changes {
+		objects = append(objects, jsonChange{
+			Change: change.Kind(),
+			Stage:  change.Stage,
+			Action: change.Action,
+			Path:   change.Path,
+			Old:    change.Old,
+			New:    change.New,
+		})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(objects)
+}
+
+// Returns a pipeline definition, failing if the pipeline does not exist
+func getDefinition(ctx context.Context, cp *codepipeline.Client, pipelineName string, version int32) (*types.PipelineDeclaration, error) {
+	decl, err := awsutil.GetPipelineDefinition(ctx, cp, pipelineName, version)
+	if err != nil {
+		return nil, err
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("pipeline %s not found", pipelineName)
+	}
+
+	return decl, nil
+}
+
+// Formats a change as "+ path: value" when added, "- path: value" when removed
+// or "~ path: old -> new" when changed
+func formatChange(change definition.Change) string {
+	switch change.Kind() {
+	case "added":
+		return fmt.Sprintf("+ %s: %s", change.Path, formatValue(change.New))
+	case "removed":
+		return fmt.Sprintf("- %s: %s", change.Path, formatValue(change.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", change.Path, formatValue(change.Old), formatValue(change.New))
+	}
+}
+
+// Formats scalars as they are and anything else as compact JSON
+func formatValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
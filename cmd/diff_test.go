@@ -0,0 +1,23 @@
+package cmd
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	out := runCph(t, "diff", "", "diff", "alpha", "beta")
+	assertGolden(t, "diff", out)
+}
+
+func TestDiffJSON(t *testing.T) {
+	out := runCph(t, "diff", "", "diff", "alpha", "beta", "-o", "json")
+	assertGolden(t, "diff_json", out)
+}
+
+func TestDiffVersion(t *testing.T) {
+	out := runCph(t, "diff_version", "", "diff", "alpha", "--version", "2")
+	assertGolden(t, "diff_version", out)
+}
+
+func TestDiffSame(t *testing.T) {
+	out := runCph(t, "diff_same", "", "diff", "alpha", "alpha")
+	assertGolden(t, "diff_same", out)
+}
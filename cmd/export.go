@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/definition"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [pipeline]",
+	Short: "Write pipeline definitions to YAML or JSON files.",
+	Long: `Write the definition of a pipeline, or of every pipeline matching --name, to
+<pipeline>.yaml or <pipeline>.json. Metadata such as the version is left out,
+so the files can be edited and applied with cph apply.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePipelineNameArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		dir, err := cmd.Flags().GetString("dir")
+		if err != nil {
+			return err
+		}
+
+		var pipelineName string
+		if len(args) == 1 {
+			pipelineName = args[0]
+		}
+
+		return exportPipelines(cmd.Context(), pipelineName, name, format, dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("name", "", "Use a name or part of a name to filter the exported pipelines.")
+	exportCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	exportCmd.Flags().String("format", "yaml", "File format to write, yaml or json.")
+	exportCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return definition.Formats, cobra.ShellCompDirectiveNoFileComp
+	})
+	exportCmd.Flags().String("dir", ".", "Directory to write the files to.")
+	exportCmd.MarkFlagDirname("dir")
+}
+
+// Writes the definition of the named pipeline, or of every pipeline matching
+// the search term, to a file per pipeline.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines (without a pipeline name)
+// 2. GetPipeline for each pipeline
+func exportPipelines(ctx context.Context, pipelineName string, searchTerm string, format string, dir string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipelineNames := []string{pipelineName}
+	if pipelineName == "" {
+		pipelineNames, err = awsutil.GetPipelineNames(ctx, cp, searchTerm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, name := range pipelineNames {
+		decl, err := awsutil.GetPipelineDefinition(ctx, cp, name, 0)
+		if err != nil {
+			return err
+		}
+		if decl == nil {
+			return fmt.Errorf("pipeline %s not found", name)
+		}
+
+		data, err := definition.Marshal(decl, format)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name+"."+format)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %s to %s\n", name, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	out := runCph(t, "definition", "", "export", "alpha", "--dir", dir)
+	assertGolden(t, "export", strings.ReplaceAll(out, dir, "<dir>"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "alpha.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "export_alpha_yaml", string(data))
+}
+
+func TestExportJSON(t *testing.T) {
+	dir := t.TempDir()
+	runCph(t, "definition", "", "export", "alpha", "--dir", dir, "--format", "json")
+
+	data, err := os.ReadFile(filepath.Join(dir, "alpha.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "export_alpha_json", string(data))
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+	"github.com/shreyasrama/cph/pkg/awsutil"
+)
+
+// freezeCmd represents the freeze command
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Disable inbound transitions into stages of CodePipelines based on a provided search term.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, stages, err := transitionFlags(cmd)
+		if err != nil {
+			return err
+		}
+		reason, err := cmd.Flags().GetString("reason")
+		if err != nil {
+			return err
+		}
+		if err := validateFreezeReason(reason); err != nil {
+			return err
+		}
+
+		return setStageTransitions(cmd.Context(), name, stages, false, reason)
+	},
+}
+
+// unfreezeCmd represents the unfreeze command
+var unfreezeCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "Enable inbound transitions into stages of CodePipelines based on a provided search term.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, stages, err := transitionFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		return setStageTransitions(cmd.Context(), name, stages, true, "")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(unfreezeCmd)
+
+	for _, cmd := range []*cobra.Command{freezeCmd, unfreezeCmd} {
+		cmd.Flags().String("name", "", "Use a name or part of a name to filter the pipelines.")
+		cmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+		cmd.Flags().StringSlice("stage", nil, "Name of a stage whose inbound transition is changed. Can be repeated or comma separated.")
+		cmd.MarkFlagRequired("stage")
+	}
+	freezeCmd.Flags().String("reason", "", "Why the stages are frozen. Shown in the CodePipeline console.")
+	freezeCmd.MarkFlagRequired("reason")
+}
+
+// Longest reason accepted by DisableStageTransition
+const maxFreezeReasonLength = 300
+
+// Characters accepted in a DisableStageTransition reason
+var freezeReasonPattern = regexp.MustCompile(`^[a-zA-Z0-9!@ ().*?\-]+$`)
+
+func transitionFlags(cmd *cobra.Command) (string, []string, error) {
+	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return "", nil, err
+	}
+	stages, err := cmd.Flags().GetStringSlice("stage")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, stages, nil
+}
+
+// Checks the reason before anything is changed, so an invalid reason does not
+// leave some pipelines frozen and others not
+func validateFreezeReason(reason string) error {
+	if len(reason) > maxFreezeReasonLength {
+		return fmt.Errorf("reason must be at most %v characters", maxFreezeReasonLength)
+	}
+	if !freezeReasonPattern.MatchString(reason) {
+		return fmt.Errorf("reason may only contain letters, numbers, spaces and !@().*?-")
+	}
+
+	return nil
+}
+
+// Core logic for the freeze and unfreeze features.
+// Only pipelines that have every chosen stage are listed for selection.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines
+// 2. GetPipelineState for each pipeline found
+// 3. DisableStageTransition or EnableStageTransition for each chosen pipeline and stage
+func setStageTransitions(ctx context.Context, searchTerm string, stages []string, enable bool, reason string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
+	if err != nil {
+		return err
+	}
+
+	var matching []string
+	for _, name := range pipelineNames {
+		stageNames, err := awsutil.GetStageNames(ctx, cp, name)
+		if err != nil {
+			return err
+		}
+		if hasStages(stageNames, stages) {
+			matching = append(matching, name)
+		}
+	}
+	if len(matching) == 0 {
+		fmt.Printf("No pipelines have the stages: %s\n", strings.Join(stages, ", "))
+		return nil
+	}
+
+	verb := "freeze"
+	if enable {
+		verb = "unfreeze"
+	}
+
+	fmt.Printf("\n%s\n", "The following pipelines have been found:")
+	for i, name := range matching {
+		fmt.Printf("    [%v] %s (%s)\n", i+1, name, strings.Join(stages, ", "))
+	}
+
+	chosen, err := promptPipelineSelection(ctx, matching, verb, false)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range chosen.Names {
+		for _, stage := range stages {
+			if err := setStageTransitionAudited(ctx, cp, name, stage, enable, reason); err != nil {
+				return err
+			}
+			if enable {
+				fmt.Printf("Unfroze %s in %s\n", stage, name)
+			} else {
+				fmt.Printf("Froze %s in %s\n", stage, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Enables or disables the inbound transition into a stage and records it in
+// the audit log, along with the reason
+func setStageTransitionAudited(ctx context.Context, cp *codepipeline.Client, pipelineName string, stageName string, enable bool, reason string) error {
+	var err error
+	command := "freeze"
+	if enable {
+		command = "unfreeze"
+		err = awsutil.EnableStageTransition(ctx, cp, pipelineName, stageName)
+	} else {
+		err = awsutil.DisableStageTransition(ctx, cp, pipelineName, stageName, reason)
+	}
+	auditRecord(ctx, audit.Record{Command: command, Pipeline: pipelineName, Stage: stageName, Reason: reason}, err)
+
+	return err
+}
+
+// Returns whether every wanted stage is one of the pipeline's stages
+func hasStages(stageNames []string, wanted []string) bool {
+	for _, stage := range wanted {
+		found := false
+		for _, name := range stageNames {
+			if name == stage {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
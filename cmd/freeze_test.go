@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+)
+
+func TestFreeze(t *testing.T) {
+	out := runCph(t, "freeze", "yes\n", "freeze", "--stage", "Deploy", "--reason", "Release freeze (INC-42)")
+	assertGolden(t, "freeze", out)
+
+	records, err := audit.Query(time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %v audit records, want 2", len(records))
+	}
+	for _, record := range records {
+		if record.Command != "freeze" || record.Stage != "Deploy" || record.Reason != "Release freeze (INC-42)" || record.CallerArn == "" {
+			t.Errorf("unexpected audit record: %+v", record)
+		}
+	}
+}
+
+func TestFreezeSelection(t *testing.T) {
+	out := runCph(t, "freeze_selection", "2\n", "freeze", "--stage", "Deploy", "--reason", "Release freeze (INC-42)")
+	assertGolden(t, "freeze_selection", out)
+}
+
+func TestFreezeDryRun(t *testing.T) {
+	out := runCph(t, "freeze_dry_run", "yes\n", "freeze", "--stage", "Deploy", "--reason", "Release freeze (INC-42)", "--dry-run")
+	assertGolden(t, "freeze_dry_run", out)
+}
+
+func TestValidateFreezeReason(t *testing.T) {
+	valid := []string{"Release freeze (INC-42)", "Frozen by tester@example!"}
+	for _, reason := range valid {
+		if err := validateFreezeReason(reason); err != nil {
+			t.Errorf("validateFreezeReason(%q) = %v, want nil", reason, err)
+		}
+	}
+
+	invalid := []string{"", "frozen by arn:aws:iam::123456789012:user/tester", strings.Repeat("a", 301)}
+	for _, reason := range invalid {
+		if err := validateFreezeReason(reason); err == nil {
+			t.Errorf("validateFreezeReason(%q) = nil, want an error", reason)
+		}
+	}
+}
+
+func TestUnfreeze(t *testing.T) {
+	out := runCph(t, "unfreeze", "yes\n", "unfreeze", "--stage", "Deploy")
+	assertGolden(t, "unfreeze", out)
+}
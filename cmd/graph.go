@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/graph"
+)
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph <pipeline>",
+	Short: "Draw a pipeline's stages, actions and artifacts as a graph.",
+	Long: `Draw a pipeline's stages, the order its actions run in and the artifacts
+passed between them, as text or as Mermaid or Graphviz DOT source. With
+--status, stages and actions are coloured by their latest status.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePipelineNameArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		status, err := cmd.Flags().GetBool("status")
+		if err != nil {
+			return err
+		}
+
+		return drawGraph(cmd.Context(), args[0], format, status)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().String("format", "ascii", "Graph format: ascii, mermaid or dot.")
+	graphCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return graph.Formats, cobra.ShellCompDirectiveNoFileComp
+	})
+	graphCmd.Flags().Bool("status", false, "Colour stages and actions by their latest status.")
+}
+
+// Core logic for the graph feature.
+// Makes the following calls to CodePipeline:
+// 1. GetPipeline
+// 2. GetPipelineState (with --status)
+func drawGraph(ctx context.Context, pipelineName string, format string, status bool) error {
+	if err := graph.ValidateFormat(format); err != nil {
+		return err
+	}
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	decl, err := getDefinition(ctx, cp, pipelineName, 0)
+	if err != nil {
+		return err
+	}
+
+	var state []types.StageState
+	if status {
+		result, err := awsutil.GetPipelineState(ctx, cp, pipelineName)
+		if err != nil {
+			return err
+		}
+		state = result.StageStates
+	}
+
+	g := graph.Build(decl, state)
+	if format == "ascii" {
+		fmt.Print(graph.ASCIIWith(g, func(s string) string { return colorStatus(s, s) }))
+		return nil
+	}
+
+	out, err := graph.Render(g, format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+
+	return nil
+}
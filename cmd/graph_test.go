@@ -0,0 +1,23 @@
+package cmd
+
+import "testing"
+
+func TestGraph(t *testing.T) {
+	out := runCph(t, "graph_definition", "", "graph", "alpha")
+	assertGolden(t, "graph", out)
+}
+
+func TestGraphStatus(t *testing.T) {
+	out := runCph(t, "graph", "", "graph", "alpha", "--status")
+	assertGolden(t, "graph_status", out)
+}
+
+func TestGraphMermaid(t *testing.T) {
+	out := runCph(t, "graph", "", "graph", "alpha", "--format", "mermaid", "--status")
+	assertGolden(t, "graph_mermaid", out)
+}
+
+func TestGraphDot(t *testing.T) {
+	out := runCph(t, "graph", "", "graph", "alpha", "--format", "dot", "--status")
+	assertGolden(t, "graph_dot", out)
+}
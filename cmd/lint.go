@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/config"
+	"github.com/shreyasrama/cph/pkg/definition"
+	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/lint"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [pipeline...]",
+	Short: "Check pipeline definitions against team rules.",
+	Long: `Check the definitions of pipelines, or of definition files given with -f,
+against the built-in rules and the custom rules in the config file. Exits with
+a non-zero status if any violation is at least as severe as --fail-on.`,
+	ValidArgsFunction: completePipelineNames,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		files, err := cmd.Flags().GetStringSlice("file")
+		if err != nil {
+			return err
+		}
+		failOn, err := cmd.Flags().GetString("fail-on")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		listRules, err := cmd.Flags().GetBool("list-rules")
+		if err != nil {
+			return err
+		}
+
+		linter, err := newLinter()
+		if err != nil {
+			return err
+		}
+		if listRules {
+			return showLintRules(linter, output)
+		}
+
+		return lintPipelines(cmd.Context(), linter, args, name, files, failOn, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().String("name", "", "Use a name or part of a name to filter the checked pipelines.")
+	lintCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	lintCmd.Flags().StringSliceP("file", "f", nil, "Check this definition file instead of live pipelines. Can be repeated.")
+	lintCmd.MarkFlagFilename("file", "yaml", "yml", "json")
+	lintCmd.Flags().String("fail-on", "error", "Lowest severity that fails the command: error, warning, info or never.")
+	lintCmd.Flags().Bool("list-rules", false, "List the rules and their severities instead of checking pipelines.")
+}
+
+// Creates a linter from the lint settings in the config file
+func newLinter() (*lint.Linter, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := lint.Options{
+		ProdPattern: cfg.Lint.ProdPattern,
+		Severities:  make(map[string]lint.Severity),
+	}
+	for name, s := range cfg.Lint.Rules {
+		severity, err := lint.ParseSeverity(s)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %s: %w", name, err)
+		}
+		opts.Severities[name] = severity
+	}
+	for _, rule := range cfg.Lint.Custom {
+		var severity lint.Severity
+		if rule.Severity != "" {
+			severity, err = lint.ParseSeverity(rule.Severity)
+			if err != nil {
+				return nil, fmt.Errorf("lint rule %s: %w", rule.Name, err)
+			}
+		}
+		opts.Custom = append(opts.Custom, lint.CustomRule{
+			Name:      rule.Name,
+			Severity:  severity,
+			Message:   rule.Message,
+			Pipelines: rule.Pipelines,
+			Stage:     rule.Stage,
+			Category:  rule.Category,
+			Provider:  rule.Provider,
+			Config:    rule.Config,
+			Pattern:   rule.Pattern,
+		})
+	}
+
+	return lint.New(opts)
+}
+
+func showLintRules(linter *lint.Linter, output string) error {
+	var rows [][]string
+	for _, rule := range linter.Rules() {
+		rows = append(rows, []string{rule.Name, string(rule.Severity), rule.Description})
+	}
+
+	return helpers.RenderOutput(output, []string{"Rule", "Severity", "Description"}, rows)
+}
+
+// Core logic for the lint feature.
+// Makes the following calls to CodePipeline, unless files are given:
+// 1. ListPipelines (without pipeline names)
+// 2. GetPipeline for each pipeline
+func lintPipelines(ctx context.Context, linter *lint.Linter, pipelineNames []string, searchTerm string, files []string, failOn string, output string) error {
+	failLevel := 0
+	if failOn != "never" {
+		severity, err := lint.ParseSeverity(failOn)
+		if err != nil || severity == lint.Off {
+			return fmt.Errorf("invalid --fail-on %q, must be one of: error, warning, info, never", failOn)
+		}
+		failLevel = severity.Level()
+	}
+
+	var decls []*types.PipelineDeclaration
+	if len(files) > 0 {
+		for _, file := range files {
+			decl, err := definition.Load(file)
+			if err != nil {
+				return err
+			}
+			decls = append(decls, decl)
+		}
+	} else {
+		cp, err := awsutil.CreateCodePipelineClient(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pipelineNames) == 0 {
+			pipelineNames, err = awsutil.GetPipelineNames(ctx, cp, searchTerm)
+			if err != nil {
+				return err
+			}
+		}
+		for _, name := range pipelineNames {
+			decl, err := getDefinition(ctx, cp, name, 0)
+			if err != nil {
+				return err
+			}
+			decls = append(decls, decl)
+		}
+	}
+
+	var rows [][]string
+	counts := make(map[lint.Severity]int)
+	failed := 0
+	for _, decl := range decls {
+		for _, v := range linter.Lint(decl) {
+			rows = append(rows, []string{v.Pipeline, string(v.Severity), v.Rule, v.Stage, v.Action, v.Message})
+			counts[v.Severity]++
+			if failLevel > 0 && v.Severity.Level() >= failLevel {
+				failed++
+			}
+		}
+	}
+
+	if output == "table" && len(rows) == 0 {
+		fmt.Printf("No violations found in %v pipelines.\n", len(decls))
+	} else {
+		if err := helpers.RenderOutput(output, []string{"Pipeline", "Severity", "Rule", "Stage", "Action", "Message"}, rows); err != nil {
+			return err
+		}
+		if output == "table" {
+			fmt.Printf("%v errors, %v warnings and %v info in %v pipelines.\n", counts[lint.Error], counts[lint.Warning], counts[lint.Info], len(decls))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v violations of severity %s or higher", failed, failOn)
+	}
+
+	return nil
+}
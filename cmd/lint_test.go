@@ -0,0 +1,26 @@
+package cmd
+
+import "testing"
+
+func TestLint(t *testing.T) {
+	out, err := runCphErr(t, "lint", "", "lint")
+	if err == nil {
+		t.Error("cph lint succeeded, want it to fail on errors")
+	}
+	assertGolden(t, "lint", out)
+}
+
+func TestLintFailOnNever(t *testing.T) {
+	out := runCph(t, "lint_fail_on_never", "", "lint", "gamma-prod", "--fail-on", "never")
+	assertGolden(t, "lint_clean", out)
+}
+
+func TestLintFile(t *testing.T) {
+	out := runCph(t, "none", "", "lint", "-f", "testdata/definitions/alpha.yaml")
+	assertGolden(t, "lint_file", out)
+}
+
+func TestLintRules(t *testing.T) {
+	out := runCph(t, "none", "", "lint", "--list-rules")
+	assertGolden(t, "lint_rules", out)
+}
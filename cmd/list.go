@@ -1,10 +1,9 @@
 package cmd
 
 import (
-	"fmt"
-	"time"
+	"context"
 
-	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
@@ -15,14 +14,17 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List AWS CodePipelines you have access to.",
-	Run: func(cmd *cobra.Command, args []string) {
-		name, _ := cmd.Flags().GetString("name")
-
-		if name != "" {
-			listPipelines(name)
-		} else {
-			listPipelines("")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
 		}
+
+		return listPipelines(cmd.Context(), name, output)
 	},
 }
 
@@ -34,15 +36,23 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	listCmd.PersistentFlags().String("name", "", "Use a name or part of a name to filter the listed pipelines.")
+	listCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// Shown in place of a status and revision when there is none
+const (
+	neverRunState = "Never run"
+	noRevision    = "No revision"
+)
+
+// PipelineExecSummary is nil for pipelines that have never been executed
 type pipelineExecSummary struct {
 	PipelineName        string
-	PipelineExecSummary codepipeline.PipelineExecutionSummary
+	PipelineExecSummary *types.PipelineExecutionSummary
 }
 
 // List all pipelines
@@ -50,13 +60,13 @@ type pipelineExecSummary struct {
 // 1. ListPipelines
 // 2. ListPipelineExecutions
 // 3. GetPipelineState
-func listPipelines(searchTerm string) error {
-	cp, err := awsutil.CreateCodePipelineSession()
+func listPipelines(ctx context.Context, searchTerm string, output string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	pipeline_names, err := awsutil.GetPipelineNames(cp, searchTerm)
+	pipeline_names, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
 	if err != nil {
 		return err
 	}
@@ -65,59 +75,90 @@ func listPipelines(searchTerm string) error {
 	// execution status and create a slice of structs
 	var pipeline_status []pipelineExecSummary
 	for _, name := range pipeline_names {
-		latestExecution, err := awsutil.GetLatestPipelineExecution(cp, name)
+		latestExecution, err := awsutil.GetLatestPipelineExecution(ctx, cp, name)
 		if err != nil {
 			return err
 		}
 		pipeline_status = append(pipeline_status, pipelineExecSummary{PipelineName: name, PipelineExecSummary: latestExecution})
 	}
 
-	// Print output in readable format
-	table := helpers.SetupTable([]string{"Name", "Latest State", "Last Update", "Revision"})
-
+	// Print output in the chosen format
+	var rows [][]string
 	for _, pipeline := range pipeline_status {
-		loc, err := time.LoadLocation("Local")
-		if err != nil {
-			fmt.Println("Error loading timezone data: ", err)
-			return err
+		if pipeline.PipelineExecSummary == nil {
+			state := neverRunState
+			if output == "table" {
+				state = color.New(color.Faint).Sprint(neverRunState)
+			}
+			rows = append(rows, []string{pipeline.PipelineName, state, "-", noRevision})
+			continue
 		}
-		date := pipeline.PipelineExecSummary.LastUpdateTime.In(loc).Format("Jan 02 2006 15:04:05")
-		stageInfo, err := awsutil.GetLastExecutedStage(cp, pipeline.PipelineName)
+
+		date := "-"
+		if pipeline.PipelineExecSummary.LastUpdateTime != nil {
+			date = helpers.FormatTime(*pipeline.PipelineExecSummary.LastUpdateTime, output)
+		}
+		stageInfo, err := awsutil.GetLastExecutedStage(ctx, cp, pipeline.PipelineName)
 		if err != nil {
 			return err
 		}
 
-		table.Append([]string{
+		state := getStatusColor(*pipeline.PipelineExecSummary, stageInfo.StageName)
+		if output != "table" {
+			state = statusText(*pipeline.PipelineExecSummary, stageInfo.StageName)
+		}
+
+		rows = append(rows, []string{
 			pipeline.PipelineName,
-			getStatusColor(pipeline.PipelineExecSummary, stageInfo.StageName),
+			state,
 			date,
-			*pipeline.PipelineExecSummary.SourceRevisions[0].RevisionSummary,
+			getRevisionSummary(*pipeline.PipelineExecSummary),
 		})
 	}
 
-	table.Render()
+	return helpers.RenderOutput(output, []string{"Name", "Latest State", "Last Update", "Revision"}, rows)
+}
+
+// Returns the status of an execution and the stage it is at, if known
+func statusText(pes types.PipelineExecutionSummary, stage string) string {
+	if stage == "" {
+		return string(pes.Status)
+	}
+	return string(pes.Status) + " - " + stage
+}
+
+// Returns the summary of the execution's first source revision, e.g. a commit
+// message, or noRevision when the source didn't provide one
+func getRevisionSummary(pes types.PipelineExecutionSummary) string {
+	if len(pes.SourceRevisions) == 0 || pes.SourceRevisions[0].RevisionSummary == nil {
+		return noRevision
+	}
+	return *pes.SourceRevisions[0].RevisionSummary
+}
 
-	return nil
+func getStatusColor(pes types.PipelineExecutionSummary, stage string) string {
+	return colorStatus(string(pes.Status), statusText(pes, stage))
 }
 
-func getStatusColor(pes codepipeline.PipelineExecutionSummary, stage string) string {
-	switch *pes.Status {
+// Colours text by the execution status it describes
+func colorStatus(status string, text string) string {
+	switch status {
 	case "InProgress":
 		blue := color.New(color.FgBlue).SprintFunc()
-		return blue(*pes.Status, " - ", stage)
+		return blue(text)
 	case "Failed", "Stopped", "Cancelled":
 		red := color.New(color.FgRed).SprintFunc()
-		return red(*pes.Status, " - ", stage)
+		return red(text)
 	case "Stopping":
 		yellow := color.New(color.FgYellow).SprintFunc()
-		return yellow(*pes.Status, " - ", stage)
+		return yellow(text)
 	case "Succeeded":
 		green := color.New(color.FgGreen).SprintFunc()
-		return green(*pes.Status, " - ", stage)
+		return green(text)
 	case "Superseded":
 		black := color.New(color.FgBlack).SprintFunc()
-		return black(*pes.Status, " - ", stage)
+		return black(text)
 	default:
-		return *pes.Status
+		return text
 	}
 }
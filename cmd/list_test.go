@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	out := runCph(t, "list", "", "list")
+	assertGolden(t, "list", out)
+}
+
+func TestListJSON(t *testing.T) {
+	out := runCph(t, "list", "", "list", "--output", "json")
+	assertGolden(t, "list_json", out)
+}
+
+func TestListWithName(t *testing.T) {
+	out := runCph(t, "list_with_name", "", "list", "--name", "beta", "--output", "csv")
+	assertGolden(t, "list_name", out)
+}
+
+func TestListNeverRun(t *testing.T) {
+	out := runCph(t, "list_never_run", "", "list")
+	assertGolden(t, "list_never_run", out)
+}
+
+func TestListDenied(t *testing.T) {
+	_, err := runCphErr(t, "list_denied", "", "list", "--output", "json")
+	if err == nil || !strings.Contains(err.Error(), "AccessDeniedException") {
+		t.Errorf("cph list: %v, want the access denied error", err)
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <pipeline>",
+	Short: "Show the CodeBuild logs of a failed action in a pipeline's latest execution.",
+	Long: `Show the CodeBuild logs of an action in a pipeline's latest execution.
+By default the failed action is used, --stage and --action choose another one.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePipelineNameArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stage, err := cmd.Flags().GetString("stage")
+		if err != nil {
+			return err
+		}
+		action, err := cmd.Flags().GetString("action")
+		if err != nil {
+			return err
+		}
+		follow, err := cmd.Flags().GetBool("follow")
+		if err != nil {
+			return err
+		}
+
+		return showLogs(cmd.Context(), args[0], stage, action, follow)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().String("stage", "", "Show the logs of an action in this stage instead of the failed action.")
+	logsCmd.Flags().String("action", "", "Show the logs of this action instead of the failed action.")
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep showing new log events until the build completes.")
+}
+
+// How often new log events are fetched with --follow
+const logsPollInterval = 5 * time.Second
+
+// Resolves the action's execution and writes the logs of its CodeBuild build.
+// Makes the following calls:
+// 1. CodePipeline ListPipelineExecutions
+// 2. CodePipeline ListActionExecutions
+// 3. CodeBuild BatchGetBuilds
+// 4. CloudWatch Logs GetLogEvents
+func showLogs(ctx context.Context, pipelineName string, stage string, action string, follow bool) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	detail, err := awsutil.FindActionExecution(ctx, cp, pipelineName, stage, action)
+	if err != nil {
+		return err
+	}
+
+	var externalId, externalUrl string
+	if detail.Output != nil && detail.Output.ExecutionResult != nil {
+		externalId = aws.ToString(detail.Output.ExecutionResult.ExternalExecutionId)
+		externalUrl = aws.ToString(detail.Output.ExecutionResult.ExternalExecutionUrl)
+	}
+	var provider string
+	if detail.Input != nil && detail.Input.ActionTypeId != nil {
+		provider = aws.ToString(detail.Input.ActionTypeId.Provider)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s / %s (%s, %s)\n", aws.ToString(detail.StageName), aws.ToString(detail.ActionName), provider, detail.Status)
+
+	if provider != "CodeBuild" {
+		if externalUrl != "" {
+			fmt.Fprintf(os.Stderr, "Details: %s\n", externalUrl)
+		}
+		return fmt.Errorf("logs can only be shown for CodeBuild actions, %s is a %s action", aws.ToString(detail.ActionName), provider)
+	}
+	if externalId == "" {
+		return fmt.Errorf("action %s has not started a build yet", aws.ToString(detail.ActionName))
+	}
+
+	cb, err := awsutil.CreateCodeBuildClient(ctx)
+	if err != nil {
+		return err
+	}
+	logs, err := awsutil.CreateCloudWatchLogsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	return awsutil.WriteBuildLogs(ctx, cb, logs, externalId, follow, logsPollInterval, os.Stdout)
+}
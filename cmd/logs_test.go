@@ -0,0 +1,20 @@
+package cmd
+
+import "testing"
+
+func TestLogs(t *testing.T) {
+	out := runCph(t, "logs", "", "logs", "alpha")
+	assertGolden(t, "logs", out)
+}
+
+func TestLogsServiceEndpoints(t *testing.T) {
+	// Requests are replayed by the service named in their host, so a service
+	// falling back to the default endpoint finds nothing to replay
+	out := runCph(t, "logs", "", "logs", "alpha",
+		"--endpoint-url", "http://default.invalid",
+		"--codepipeline-endpoint-url", "http://codepipeline.invalid",
+		"--sts-endpoint-url", "http://sts.invalid",
+		"--codebuild-endpoint-url", "http://codebuild.invalid",
+		"--logs-endpoint-url", "http://logs.invalid")
+	assertGolden(t, "logs", out)
+}
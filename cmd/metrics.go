@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/metrics"
+)
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show delivery metrics of CodePipelines based on a provided search term.",
+	Long: `Show delivery metrics for each pipeline from its execution history: success
+rate, mean and p90 duration, failures per stage, deployment frequency (succeeded
+executions per day) and mean time to recover from a failure.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		since, err := cmd.Flags().GetString("since")
+		if err != nil {
+			return err
+		}
+		until, err := cmd.Flags().GetString("until")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		return showMetrics(cmd.Context(), name, since, until, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+
+	metricsCmd.Flags().String("name", "", "Use a name or part of a name to filter the pipelines.")
+	metricsCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	metricsCmd.Flags().String("since", "720h", "Only include executions started after this time, e.g. 168h, 2006-01-02 or an RFC 3339 timestamp.")
+	metricsCmd.Flags().String("until", "", "Only include executions started before this time. Defaults to now.")
+}
+
+// Core logic for the metrics feature.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines
+// 2. ListPipelineExecutions for each pipeline, until executions are older than --since
+// 3. ListActionExecutions for each failed execution between --since and --until, to find the stage that failed
+func showMetrics(ctx context.Context, searchTerm string, since string, until string, output string) error {
+	now := time.Now()
+	sinceTime, err := helpers.ParseTimeFlag(since, now)
+	if err != nil {
+		return err
+	}
+	untilTime, err := helpers.ParseTimeFlag(until, now)
+	if err != nil {
+		return err
+	}
+	if untilTime.IsZero() {
+		untilTime = now
+	}
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, name := range pipelineNames {
+		summaries, err := awsutil.GetPipelineExecutions(ctx, cp, name, sinceTime)
+		if err != nil {
+			return err
+		}
+
+		var executions []metrics.Execution
+		for _, summary := range summaries {
+			// Executions outside the window aren't counted, so don't look up why they failed
+			if !metrics.InWindow(aws.ToTime(summary.StartTime), sinceTime, untilTime) {
+				continue
+			}
+			execution := metrics.Execution{
+				Id:     aws.ToString(summary.PipelineExecutionId),
+				Status: string(summary.Status),
+				Start:  aws.ToTime(summary.StartTime),
+				End:    aws.ToTime(summary.LastUpdateTime),
+			}
+			if summary.Status == types.PipelineExecutionStatusFailed {
+				execution.FailedStage, err = failedStage(ctx, cp, name, execution.Id)
+				if err != nil {
+					return err
+				}
+			}
+			executions = append(executions, execution)
+		}
+
+		s := metrics.Summarise(name, executions, sinceTime, untilTime)
+		rows = append(rows, []string{
+			s.Pipeline,
+			fmt.Sprint(s.Executions),
+			fmt.Sprint(s.Succeeded),
+			fmt.Sprint(s.Failed),
+			formatRate(s.SuccessRate),
+			formatMetricDuration(s.MeanDuration, s.Succeeded+s.Failed),
+			formatMetricDuration(s.P90Duration, s.Succeeded+s.Failed),
+			fmt.Sprintf("%.2f", s.DeploymentsPerDay),
+			formatMetricDuration(s.MeanTimeToRecover, s.Recoveries),
+			formatStageCounts(s.FailuresByStage),
+		})
+	}
+
+	if output == "table" {
+		fmt.Printf("Executions started between %s and %s\n\n", sinceTime.Local().Format("Jan 02 2006 15:04"), untilTime.Local().Format("Jan 02 2006 15:04"))
+	}
+
+	return helpers.RenderOutput(output, []string{"Pipeline", "Executions", "Succeeded", "Failed", "Success Rate", "Mean Duration", "P90 Duration", "Deploys Per Day", "MTTR", "Failures By Stage"}, rows)
+}
+
+// Returns the stage of the failed action in a failed execution
+func failedStage(ctx context.Context, cp *codepipeline.Client, pipelineName string, executionId string) (string, error) {
+	details, err := awsutil.GetActionExecutions(ctx, cp, pipelineName, executionId)
+	if err != nil {
+		return "", err
+	}
+	for _, detail := range details {
+		if detail.Status == types.ActionExecutionStatusFailed {
+			return aws.ToString(detail.StageName), nil
+		}
+	}
+
+	return "", nil
+}
+
+func formatRate(rate float64) string {
+	if math.IsNaN(rate) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// Formats a duration computed from count values, "-" if there were none
+func formatMetricDuration(d time.Duration, count int) string {
+	if count == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+// Formats counts per stage as "Build: 2, Deploy: 1", sorted by stage
+func formatStageCounts(counts map[string]int) string {
+	var stages []string
+	for stage := range counts {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	var parts []string
+	for _, stage := range stages {
+		parts = append(parts, fmt.Sprintf("%s: %v", stage, counts[stage]))
+	}
+	return strings.Join(parts, ", ")
+}
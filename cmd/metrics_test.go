@@ -0,0 +1,19 @@
+package cmd
+
+import "testing"
+
+func TestMetrics(t *testing.T) {
+	out := runCph(t, "metrics", "", "metrics", "--since", "2023-11-10", "--until", "2023-11-20")
+	assertGolden(t, "metrics", out)
+}
+
+func TestMetricsJSON(t *testing.T) {
+	out := runCph(t, "metrics_json", "", "metrics", "--name", "alpha", "--since", "2023-11-10", "--until", "2023-11-20", "-o", "json")
+	assertGolden(t, "metrics_json", out)
+}
+
+func TestMetricsUntil(t *testing.T) {
+	// exec-3 failed after --until, so the stage it failed in isn't looked up
+	out := runCph(t, "metrics_until", "", "metrics", "--name", "alpha", "--since", "2023-11-10", "--until", "2023-11-11T08:00:00Z", "-o", "json")
+	assertGolden(t, "metrics_until", out)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/config"
+	"github.com/shreyasrama/cph/pkg/notify"
+)
+
+// How often in-progress executions are checked while waiting on them
+var executionPollInterval = 15 * time.Second
+
+// Returns a notifier sending to the sinks in the config file
+func newNotifier() (*notify.Notifier, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := notify.New()
+	for i, n := range cfg.Notifications {
+		var sink notify.Sink
+		switch n.Type {
+		case "webhook":
+			if n.URL == "" {
+				return nil, fmt.Errorf("notification %v: webhook needs a url", i+1)
+			}
+			sink, err = notify.NewWebhook(n.URL, n.Template, n.Headers)
+			if err != nil {
+				return nil, fmt.Errorf("notification %v: %w", i+1, err)
+			}
+		case "slack":
+			if n.URL == "" {
+				return nil, fmt.Errorf("notification %v: slack needs a url", i+1)
+			}
+			sink = notify.NewSlack(n.URL)
+		case "desktop":
+			sink = notify.Desktop{}
+		case "command":
+			if n.Command == "" {
+				return nil, fmt.Errorf("notification %v: command needs a command", i+1)
+			}
+			sink = notify.Command{Command: n.Command}
+		default:
+			return nil, fmt.Errorf("notification %v: unknown type %q, expected webhook, slack, desktop or command", i+1, n.Type)
+		}
+		notifier.Add(sink, n.On)
+	}
+
+	return notifier, nil
+}
+
+// Waits for executions to finish, notifying about approvals they wait for and
+// the status they finish with. Takes and returns maps keyed by execution ID,
+// of pipeline names and of final statuses. Failing to notify is reported but
+// does not stop the wait.
+func waitForExecutions(ctx context.Context, cp *codepipeline.Client, executionIds map[string]string, notifier *notify.Notifier) (map[string]types.PipelineExecutionStatus, error) {
+	ids := sortedExecutionIds(executionIds)
+
+	statuses := make(map[string]types.PipelineExecutionStatus)
+	// Approvals already notified about, so each is only sent once
+	notified := make(map[string]bool)
+	for {
+		for _, id := range ids {
+			if _, done := statuses[id]; done {
+				continue
+			}
+			name := executionIds[id]
+
+			// Dry-run executions were never started, treat them as successful
+			if awsutil.IsDryRunExecution(id) {
+				statuses[id] = types.PipelineExecutionStatusSucceeded
+				continue
+			}
+
+			status, err := awsutil.GetPipelineExecutionStatus(ctx, cp, name, id)
+			if err != nil {
+				return nil, err
+			}
+
+			if status == types.PipelineExecutionStatusInProgress || status == types.PipelineExecutionStatusStopping {
+				if !notifier.Wants(notify.WaitingForApproval) {
+					continue
+				}
+				approvals, err := awsutil.GetPendingApprovals(ctx, cp, name)
+				if err != nil {
+					return nil, err
+				}
+				for _, approval := range approvals {
+					key := id + "/" + approval.StageName + "/" + approval.ActionName
+					if approval.ExecutionId != id || notified[key] {
+						continue
+					}
+					notified[key] = true
+					sendNotification(ctx, notifier, notify.Event{
+						Pipeline:    name,
+						ExecutionId: id,
+						Transition:  notify.WaitingForApproval,
+						Stage:       approval.StageName,
+						Action:      approval.ActionName,
+					})
+				}
+				continue
+			}
+
+			statuses[id] = status
+			if !notifier.Wants(string(status)) {
+				continue
+			}
+			event := notify.Event{Pipeline: name, ExecutionId: id, Transition: string(status)}
+			if status == types.PipelineExecutionStatusFailed {
+				event.Stage, err = failedStage(ctx, cp, name, id)
+				if err != nil {
+					return nil, err
+				}
+			}
+			sendNotification(ctx, notifier, event)
+		}
+
+		if len(statuses) == len(ids) {
+			return statuses, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(executionPollInterval):
+		}
+	}
+}
+
+// Prints the event and sends it to the notifier's sinks
+func sendNotification(ctx context.Context, notifier *notify.Notifier, event notify.Event) {
+	event.Time = time.Now().UTC()
+	fmt.Printf("%s.\n", event.Message())
+
+	if err := notifier.Notify(ctx, event); err != nil {
+		fmt.Println("Error sending notification: ", err)
+	}
+}
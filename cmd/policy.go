@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/pflag"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/config"
+	"github.com/shreyasrama/cph/pkg/policy"
+)
+
+// The --reason and --override flags of commands checked against the policy,
+// and the flag letting 'yes' include protected pipelines
+type policyOptions struct {
+	Reason              string
+	Override            bool
+	AllowProtectedBatch bool
+}
+
+// Reads the --reason, --override and protected pipeline flags
+func getPolicyOptions(flags *pflag.FlagSet) (policyOptions, error) {
+	reason, err := flags.GetString("reason")
+	if err != nil {
+		return policyOptions{}, err
+	}
+	override, err := flags.GetBool("override")
+	if err != nil {
+		return policyOptions{}, err
+	}
+	if override && strings.TrimSpace(reason) == "" {
+		return policyOptions{}, errors.New("--override needs a --reason saying why the policy is being overridden")
+	}
+	allowProtectedBatch, err := flags.GetBool(protectedBatchFlag)
+	if err != nil {
+		return policyOptions{}, err
+	}
+
+	return policyOptions{Reason: reason, Override: override, AllowProtectedBatch: allowProtectedBatch}, nil
+}
+
+// Loads the policy file named in the config file, or returns nil if there is none
+func loadPolicy() (*policy.Policy, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Policy == "" {
+		return nil, nil
+	}
+
+	return policy.Load(cfg.Policy)
+}
+
+// Checks that the policy allows the command on the targets. Denials are
+// returned as an error, unless --override was given, in which case they are
+// printed and returned in the audit details the command records its actions with.
+func enforcePolicy(ctx context.Context, p *policy.Policy, command string, targets []policy.Target, opts policyOptions) (audit.Record, error) {
+	details := audit.Record{Reason: opts.Reason}
+	if p == nil {
+		return details, nil
+	}
+
+	req := policy.Request{Command: command, Targets: targets, Reason: opts.Reason, Time: clock()}
+	if command == policy.Approve && p.DenySelfApproval {
+		caller, err := awsutil.GetCallerArn(ctx)
+		if err != nil {
+			return details, err
+		}
+		req.Caller = caller
+	}
+
+	for _, warning := range p.Warnings(req) {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	denials := p.Evaluate(req)
+	if len(denials) == 0 {
+		return details, nil
+	}
+
+	if !opts.Override {
+		var b strings.Builder
+		b.WriteString("denied by policy:")
+		for _, d := range denials {
+			fmt.Fprintf(&b, "\n  %s: %s", d.Rule, d.Message)
+		}
+		b.WriteString("\nUse --override with a --reason to go ahead anyway, overrides are audited")
+		return details, errors.New(b.String())
+	}
+
+	for _, d := range denials {
+		fmt.Printf("Overriding policy %s: %s\n", d.Rule, d.Message)
+		details.Overrides = append(details.Overrides, d.Rule+": "+d.Message)
+	}
+
+	return details, nil
+}
+
+// Returns the policy targets of pipelines to run
+func runTargets(pipelineNames []string) []policy.Target {
+	targets := make([]policy.Target, 0, len(pipelineNames))
+	for _, name := range pipelineNames {
+		targets = append(targets, policy.Target{Pipeline: name})
+	}
+	return targets
+}
+
+// Returns the policy targets of approvals, sorted by pipeline name. Who
+// started each execution, and who wrote the change, are only looked up if the
+// policy denies self-approval.
+func approvalTargets(ctx context.Context, cp *codepipeline.Client, p *policy.Policy, approvals map[string]queuedApproval) ([]policy.Target, error) {
+	var targets []policy.Target
+	for _, name := range sortedPipelines(approvals) {
+		target := policy.Target{Pipeline: name}
+		if p != nil && p.DenySelfApproval {
+			var execution *types.PipelineExecution
+			if id := approvals[name].Approval.ExecutionId; id != "" {
+				var err error
+				execution, err = awsutil.GetPipelineExecution(ctx, cp, name, id)
+				if err != nil {
+					return nil, err
+				}
+			}
+			var err error
+			target, err = approvalTarget(ctx, cp, name, execution)
+			if err != nil {
+				return nil, err
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// Returns the policy target of approving an execution, with who started it
+// and who wrote its change
+func approvalTarget(ctx context.Context, cp *codepipeline.Client, pipelineName string, execution *types.PipelineExecution) (policy.Target, error) {
+	authors, err := changeAuthors(ctx, cp, pipelineName, execution)
+	if err != nil {
+		return policy.Target{}, err
+	}
+
+	return policy.Target{Pipeline: pipelineName, StartedBy: executionStartedBy(execution), Authors: authors}, nil
+}
+
+// Returns the ARN of whoever started the execution, or an empty string if it
+// was started some other way, e.g. by a source change
+func executionStartedBy(execution *types.PipelineExecution) string {
+	if execution == nil || execution.Trigger == nil || execution.Trigger.TriggerType != types.TriggerTypeStartPipelineExecution {
+		return ""
+	}
+	return aws.ToString(execution.Trigger.TriggerDetail)
+}
+
+// Returns the names and email addresses of the authors of the commits an
+// execution is running. Only CodeCommit records who wrote a commit, so other
+// sources, e.g. GitHub through a connection, have no authors.
+func changeAuthors(ctx context.Context, cp *codepipeline.Client, pipelineName string, execution *types.PipelineExecution) ([]string, error) {
+	if execution == nil {
+		return nil, nil
+	}
+	decl, err := awsutil.GetPipelineDefinition(ctx, cp, pipelineName, aws.ToInt32(execution.PipelineVersion))
+	if err != nil || decl == nil {
+		return nil, err
+	}
+
+	// Commit IDs by the name of the artifact they were checked out to
+	revisions := make(map[string]string)
+	for _, revision := range execution.ArtifactRevisions {
+		revisions[aws.ToString(revision.Name)] = aws.ToString(revision.RevisionId)
+	}
+
+	var authors []string
+	var cc *codecommit.Client
+	for _, stage := range decl.Stages {
+		for _, action := range stage.Actions {
+			if action.ActionTypeId == nil || action.ActionTypeId.Category != types.ActionCategorySource || aws.ToString(action.ActionTypeId.Provider) != "CodeCommit" {
+				continue
+			}
+			for _, artifact := range action.OutputArtifacts {
+				commitId := revisions[aws.ToString(artifact.Name)]
+				if commitId == "" {
+					continue
+				}
+				if cc == nil {
+					if cc, err = awsutil.CreateCodeCommitClient(ctx); err != nil {
+						return nil, err
+					}
+				}
+				author, err := awsutil.GetCommitAuthor(ctx, cc, action.Configuration["RepositoryName"], commitId)
+				if err != nil {
+					return nil, err
+				}
+				for _, identity := range []string{author.Name, author.Email} {
+					if identity != "" {
+						authors = append(authors, identity)
+					}
+				}
+			}
+		}
+	}
+
+	return authors, nil
+}
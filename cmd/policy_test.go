@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shreyasrama/cph/pkg/audit"
+)
+
+// Writes a policy file and points the config file at it
+func writePolicy(t *testing.T, policy string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, "policy: "+path+"\n")
+}
+
+func TestApproveSelfApprovalDenied(t *testing.T) {
+	writePolicy(t, "denySelfApproval: true\n")
+
+	_, err := runCphErr(t, "policy_denied", "yes\n", "approve")
+	want := "denied by policy:\n  self-approval: you started the execution of beta waiting for approval, someone else has to approve it\n"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("cph approve: %v, want a self-approval denial", err)
+	}
+}
+
+func TestApproveSelfApprovalAuthor(t *testing.T) {
+	writePolicy(t, "denySelfApproval: true\n")
+
+	// The execution was started by a source change, but CodeCommit records
+	// that the caller wrote it
+	_, err := runCphErr(t, "policy_codecommit", "yes\n", "approve")
+	want := "denied by policy:\n  self-approval: you wrote the change beta is waiting to approve, someone else has to approve it\n"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("cph approve: %v, want a self-approval denial", err)
+	}
+}
+
+func TestApproveUnknownAuthorDenied(t *testing.T) {
+	writePolicy(t, "denySelfApproval: true\ndenyUnknownAuthor: true\n")
+
+	_, err := runCphErr(t, "policy_denied", "yes\n", "approve")
+	if err == nil || !strings.Contains(err.Error(), "unknown-author: the author of the change beta is waiting to approve can't be found") {
+		t.Errorf("cph approve: %v, want an unknown author denial", err)
+	}
+}
+
+func TestApproveOverride(t *testing.T) {
+	writePolicy(t, "denySelfApproval: true\n")
+
+	out := runCph(t, "policy", "yes\n", "approve", "--override", "--reason", "Only approver on call")
+	assertGolden(t, "approve_override", out)
+
+	records, err := audit.Query(time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %v audit records, want 1", len(records))
+	}
+	record := records[0]
+	if record.Reason != "Only approver on call" || len(record.Overrides) != 1 || !strings.HasPrefix(record.Overrides[0], "self-approval: ") {
+		t.Errorf("audit record does not show the override: %+v", record)
+	}
+}
+
+func TestRunPolicyDenied(t *testing.T) {
+	writePolicy(t, "requireReason: [alpha]\nmaxBatchSize: 1\n")
+
+	_, err := runCphErr(t, "run_not_started", "yes\n", "run")
+	want := `denied by policy:
+  max-batch-size: cannot run 2 pipelines at once, the limit is 1
+  require-reason: alpha needs a --reason to run
+Use --override with a --reason to go ahead anyway, overrides are audited`
+	if err == nil || err.Error() != want {
+		t.Errorf("cph run: %v, want:\n%s", err, want)
+	}
+}
+
+func TestRunBlockedWindow(t *testing.T) {
+	fixClock(t)
+	writePolicy(t, `blockedWindows:
+  - name: maintenance
+    days: [Tue]
+    start: "23:00"
+    end: "24:00"
+    timezone: UTC
+`)
+
+	_, err := runCphErr(t, "run_not_started", "1\n", "run", "--name", "alpha", "--reason", "Hotfix")
+	if err == nil || !strings.Contains(err.Error(), "alpha cannot be run during maintenance (Tue 23:00-24:00 UTC)") {
+		t.Errorf("cph run: %v, want a blocked window denial", err)
+	}
+}
+
+func TestOverrideNeedsReason(t *testing.T) {
+	_, err := runCphErr(t, "none", "", "run", "--override")
+	if err == nil || !strings.Contains(err.Error(), "--override needs a --reason") {
+		t.Errorf("cph run --override: %v, want an error asking for a reason", err)
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/config"
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+// Flag letting 'yes' include protected pipelines
+const protectedBatchFlag = "i-know-this-includes-protected"
+
+// Returns which of the pipelines are protected by the config file, in the
+// same order. Tags are only looked up if the config file protects pipelines by tag.
+func protectedPipelines(ctx context.Context, cp *codepipeline.Client, pipelineNames []string) ([]string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []*regexp.Regexp
+	for _, pattern := range cfg.Protected.Pipelines {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("protected pipelines: %w", err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	var protected []string
+	for _, name := range pipelineNames {
+		matched := false
+		for _, re := range patterns {
+			if re.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched && len(cfg.Protected.Tags) > 0 {
+			tags, err := awsutil.GetPipelineTags(ctx, cp, name)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range cfg.Protected.Tags {
+				if v, ok := tags[key]; ok && v == value {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			protected = append(protected, name)
+		}
+	}
+
+	return protected, nil
+}
+
+// Has any protected pipelines among those chosen confirmed again, by typing
+// the pipeline's name, or how many there are if there are several. Choosing
+// them all with 'yes' is refused unless allowBatch is set, so a loose search
+// term can't sweep them in. Returns whether to go ahead.
+func confirmProtected(ctx context.Context, cp *codepipeline.Client, command string, pipelineNames []string, batch bool, allowBatch bool) (bool, error) {
+	protected, err := protectedPipelines(ctx, cp, pipelineNames)
+	if err != nil {
+		return false, err
+	}
+	if len(protected) == 0 {
+		return true, nil
+	}
+
+	if batch && !allowBatch {
+		return false, fmt.Errorf("'yes' would %s protected pipelines (%s), choose them by number or pass --%s", command, strings.Join(protected, ", "), protectedBatchFlag)
+	}
+
+	var prompt, want string
+	if len(protected) == 1 {
+		prompt = fmt.Sprintf("\n%s is protected. Type its name to %s it: ", protected[0], command)
+		want = protected[0]
+	} else {
+		prompt = fmt.Sprintf("\n%v protected pipelines are included (%s). Type %v to %s them: ", len(protected), strings.Join(protected, ", "), len(protected), command)
+		want = strconv.Itoa(len(protected))
+	}
+
+	s, err := helpers.PromptInput(ctx, prompt)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(s) != want {
+		fmt.Println("Cancelled.")
+		return false, nil
+	}
+
+	return true, nil
+}
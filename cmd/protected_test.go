@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// Protects app-prod by name and web by its env tag
+const protectedConfig = `protected:
+  pipelines: ["-prod$"]
+  tags:
+    env: prod
+`
+
+func TestRunProtectedBatchRefused(t *testing.T) {
+	writeConfig(t, protectedConfig)
+
+	_, err := runCphErr(t, "run_protected_batch_refused", "yes\n", "run")
+	want := "'yes' would run protected pipelines (app-prod, web), choose them by number or pass --i-know-this-includes-protected"
+	if err == nil || err.Error() != want {
+		t.Errorf("cph run: %v, want:\n%s", err, want)
+	}
+}
+
+func TestRunProtectedBatchConfirmed(t *testing.T) {
+	writeConfig(t, protectedConfig)
+
+	out := runCph(t, "protected", "yes\n2\n", "run", "--i-know-this-includes-protected")
+	assertGolden(t, "run_protected_batch", out)
+}
+
+func TestRunProtectedByName(t *testing.T) {
+	writeConfig(t, protectedConfig)
+
+	out := runCph(t, "run_protected_by_name", "2\napp-prod\n", "run")
+	assertGolden(t, "run_protected_name", out)
+}
+
+func TestRunProtectedWrongCount(t *testing.T) {
+	writeConfig(t, protectedConfig)
+
+	out := runCph(t, "run_protected_wrong_count", "2-3\n3\n", "run")
+	if !strings.HasSuffix(out, "Type 2 to run them: Cancelled.\n") || strings.Contains(out, "Running pipelines") {
+		t.Errorf("cph run did not cancel when the wrong count was typed:\n%s", out)
+	}
+}
+
+func TestApproveProtectedBatchRefused(t *testing.T) {
+	writeConfig(t, "protected:\n  pipelines: [\"^beta$\"]\n")
+
+	_, err := runCphErr(t, "approve_protected_batch_refused", "yes\n", "approve")
+	if err == nil || !strings.HasPrefix(err.Error(), "'yes' would approve protected pipelines (beta)") {
+		t.Errorf("cph approve: %v, want 'yes' to be refused", err)
+	}
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/config"
+	"github.com/shreyasrama/cph/pkg/helpers"
 )
 
 var version = "0.0.0"
@@ -16,6 +24,35 @@ var rootCmd = &cobra.Command{
 your resources in AWS CodePipeline.
 `,
 	Version: version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if err := helpers.ValidateOutputFormat(output); err != nil {
+			return err
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+		awsutil.SetDryRun(dryRun)
+
+		return configureAWS(cmd)
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		if !awsutil.DryRunEnabled() {
+			return nil
+		}
+
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		return printRecordedActions(output)
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -23,8 +60,16 @@ your resources in AWS CodePipeline.
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Ctrl-C (or SIGTERM) cancels the command's context, which stops any in-flight
+// AWS calls. A second Ctrl-C exits immediately.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -36,8 +81,125 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cph.yaml)")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Print the calls that would change pipelines instead of making them.")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Don't read or store cached AWS responses.")
+	rootCmd.PersistentFlags().Bool("refresh", false, "Ignore cached AWS responses, but store the new ones.")
+	rootCmd.PersistentFlags().Int("max-attempts", 0, "Maximum number of attempts per AWS request, including the first one. Overrides retry.maxAttempts in the config file.")
+	rootCmd.PersistentFlags().Float64("rate-limit", 0, "Maximum number of AWS requests per second. Overrides rateLimit in the config file.")
+	rootCmd.PersistentFlags().String("endpoint-url", "", "Send requests for every AWS service to this URL instead of AWS, e.g. http://localhost:4566.")
+	rootCmd.PersistentFlags().String("codepipeline-endpoint-url", "", "Send CodePipeline requests to this URL instead of AWS.")
+	rootCmd.PersistentFlags().String("sts-endpoint-url", "", "Send STS requests to this URL instead of AWS.")
+	rootCmd.PersistentFlags().String("codebuild-endpoint-url", "", "Send CodeBuild requests to this URL instead of AWS.")
+	rootCmd.PersistentFlags().String("logs-endpoint-url", "", "Send CloudWatch Logs requests to this URL instead of AWS.")
+	rootCmd.PersistentFlags().String("codecommit-endpoint-url", "", "Send CodeCommit requests to this URL instead of AWS.")
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format: table, json or csv.")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// Prints the mutating calls recorded while in dry-run mode
+func printRecordedActions(output string) error {
+	actions := awsutil.RecordedActions()
+	if output == "table" {
+		fmt.Printf("\n%s\n", "Dry run, no changes were made. The following calls would have been made:")
+	}
+
+	var rows [][]string
+	for _, action := range actions {
+		rows = append(rows, []string{
+			action.Operation,
+			action.Pipeline,
+			action.Stage,
+			action.Action,
+			action.Token,
+			action.Status,
+			action.Reason,
+		})
+	}
+
+	return helpers.RenderOutput(output, []string{"Operation", "Pipeline", "Stage", "Action", "Token", "Status", "Reason"}, rows)
+}
+
+// Sets up awsutil from the cache flags, the config file and the flags that
+// override it. Shell completion calls this itself, as flags aren't parsed when
+// PersistentPreRunE runs for it.
+func configureAWS(cmd *cobra.Command) error {
+	noCache, err := cmd.Flags().GetBool("no-cache")
+	if err != nil {
+		return err
+	}
+	refresh, err := cmd.Flags().GetBool("refresh")
+	if err != nil {
+		return err
+	}
+	if noCache {
+		awsutil.SetCacheMode(awsutil.CacheDisabled)
+	} else if refresh {
+		awsutil.SetCacheMode(awsutil.CacheRefresh)
+	} else {
+		awsutil.SetCacheMode(awsutil.CacheEnabled)
+	}
+
+	return applyConfig(cmd)
+}
+
+// Loads the config file and applies it, with any flags that override it, to awsutil
+func applyConfig(cmd *cobra.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("max-attempts") {
+		cfg.Retry.MaxAttempts, err = cmd.Flags().GetInt("max-attempts")
+		if err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("rate-limit") {
+		cfg.RateLimit, err = cmd.Flags().GetFloat64("rate-limit")
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %v", cfg.Retry.MaxAttempts)
+	}
+
+	// Endpoint flags override environment variables, which override the config file
+	endpoints := awsutil.Endpoints(cfg.Endpoints)
+	for _, e := range []struct {
+		url  *string
+		env  string
+		flag string
+	}{
+		{&endpoints.Default, "AWS_ENDPOINT_URL", "endpoint-url"},
+		{&endpoints.CodePipeline, "AWS_ENDPOINT_URL_CODEPIPELINE", "codepipeline-endpoint-url"},
+		{&endpoints.STS, "AWS_ENDPOINT_URL_STS", "sts-endpoint-url"},
+		{&endpoints.CodeBuild, "AWS_ENDPOINT_URL_CODEBUILD", "codebuild-endpoint-url"},
+		{&endpoints.CloudWatchLogs, "AWS_ENDPOINT_URL_CLOUDWATCH_LOGS", "logs-endpoint-url"},
+		{&endpoints.CodeCommit, "AWS_ENDPOINT_URL_CODECOMMIT", "codecommit-endpoint-url"},
+	} {
+		if url := os.Getenv(e.env); url != "" {
+			*e.url = url
+		}
+		if cmd.Flags().Changed(e.flag) {
+			*e.url, err = cmd.Flags().GetString(e.flag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	awsutil.SetEndpoints(endpoints)
+
+	awsutil.SetRetryOptions(awsutil.RetryOptions{
+		MaxAttempts:       cfg.Retry.MaxAttempts,
+		MinDelay:          cfg.Retry.MinDelay,
+		MaxDelay:          cfg.Retry.MaxDelay,
+		RequestsPerSecond: cfg.RateLimit,
+	})
+
+	return nil
+}
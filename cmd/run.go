@@ -1,32 +1,50 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
+	"github.com/shreyasrama/cph/pkg/audit"
 	"github.com/shreyasrama/cph/pkg/awsutil"
 	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/plan"
+	"github.com/shreyasrama/cph/pkg/policy"
 )
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
-	Use:   "run",
-	Short: "Run CodePipelines based on a provided search term.",
+	Use:          "run",
+	Short:        "Run CodePipelines based on a provided search term.",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name, err := cmd.Flags().GetString("name")
 		if err != nil {
 			return err
 		}
+		planFile, err := cmd.Flags().GetString("plan")
+		if err != nil {
+			return err
+		}
+		wait, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return err
+		}
+		opts, err := getPolicyOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-		return runPipelines(name)
+		if planFile != "" {
+			return runPlan(cmd.Context(), planFile, opts)
+		}
+		return runPipelines(cmd.Context(), name, wait, opts)
 	},
 }
 
@@ -38,6 +56,13 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	runCmd.PersistentFlags().String("name", "", "Use a name or part of a name to filter the runnable pipelines.")
+	runCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	runCmd.PersistentFlags().String("plan", "", "Run the pipelines declared in a plan file in dependency order, one wave at a time.")
+	runCmd.MarkPersistentFlagFilename("plan", "yaml", "yml")
+	runCmd.PersistentFlags().String("reason", "", "Why the pipelines are being run, recorded in the audit log.")
+	runCmd.PersistentFlags().Bool("override", false, "Run even though the policy denies it. Needs a --reason, and is recorded in the audit log.")
+	runCmd.PersistentFlags().Bool(protectedBatchFlag, false, "Let 'yes' include protected pipelines, which still have to be confirmed.")
+	runCmd.PersistentFlags().Bool("wait", false, "Wait for the started executions to finish, sending the notifications set up in the config file.")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -48,83 +73,102 @@ func init() {
 // Core logic for the run feature.
 // Notable data structures/variables:
 // pipelineNames []string - names of the pipeline that the search returned.
-// pipelineMap (map[int]string) - maps the number the pipeline corresponds to in the search results to its name.
-// executionTable (var) - table that presents the output from the run command.
-func runPipelines(searchTerm string) error {
-	cp, err := awsutil.CreateCodePipelineSession()
+// executionIds (map[string]string) - maps the started execution IDs to their pipeline name.
+func runPipelines(ctx context.Context, searchTerm string, wait bool, opts policyOptions) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+	p, err := loadPolicy()
 	if err != nil {
 		return err
 	}
 
-	pipelineNames, err := awsutil.GetPipelineNames(cp, searchTerm)
+	// Checks the chosen pipelines against the policy, and has protected ones
+	// confirmed, before running them
+	allowed := func(names []string, batch bool) (audit.Record, bool, error) {
+		details, err := enforcePolicy(ctx, p, policy.Run, runTargets(names), opts)
+		if err != nil {
+			return details, false, err
+		}
+		ok, err := confirmProtected(ctx, cp, policy.Run, names, batch, opts.AllowProtectedBatch)
+		return details, ok, err
+	}
+
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, cp, searchTerm)
 	if err != nil {
 		return err
 	}
 
 	// Print and confirm pipelines to be run
-	pipelineMap := make(map[int]string)
 	fmt.Printf("\n%s\n", "The following pipelines have been found:")
 	for i, pipeline := range pipelineNames {
-		pipelineMap[i+1] = pipeline
 		fmt.Printf("    [%v] %s\n", i+1, pipeline)
 	}
 
-	executionTable := helpers.SetupTable([]string{"Pipeline", "Execution ID"})
-
-	var s string
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Printf(
-		"\n%s",
-		`Do you want to run these pipelines?
-Enter 'yes' to run all, 'no' to cancel, a number for a specific pipeline, or provide a range or list: `)
-	if scanner.Scan() {
-		s = scanner.Text()
+	chosen, err := promptPipelineSelection(ctx, pipelineNames, "run", false)
+	if err != nil || len(chosen.Names) == 0 {
+		return err
+	}
+	details, ok, err := allowed(chosen.Names, chosen.All)
+	if err != nil || !ok {
+		return err
 	}
 
-	if i, err := strconv.Atoi(s); err == nil { // User enters a single number
-		executionId, err := awsutil.RunPipeline(cp, pipelineMap[i])
+	var executionIds map[string]string
+	if len(chosen.Names) == 1 && !chosen.All {
+		executionId, err := runPipelineAudited(ctx, cp, chosen.Names[0], details)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Started execution of %s. Execution ID: %s", pipelineMap[i], executionId)
-
-	} else if strings.EqualFold(s, "yes") {
+		fmt.Printf("Started execution of %s. Execution ID: %s\n", chosen.Names[0], executionId)
+		executionIds = map[string]string{executionId: chosen.Names[0]}
+	} else {
 		fmt.Println("Running pipelines...")
-		executionIds, err := awsutil.RunPipelines(cp, pipelineNames)
+		executionIds, err = runPipelinesAudited(ctx, cp, chosen.Names, details)
 		if err != nil {
+			// Show the executions that started before the error, as they carry on
+			if len(executionIds) > 0 {
+				renderExecutionTable(executionIds, helpers.SetupTable([]string{"Pipeline", "Execution ID"}))
+			}
 			return err
 		}
-		renderExecutionTable(executionIds, executionTable)
-
-	} else if strings.EqualFold(s, "no") {
-		fmt.Println("Cancelled.")
-		// exit?
-
-	} else { // User enters a range
-		rangeMatch, _ := regexp.MatchString("^[0-9]{1,2}-[0-9]{1,2}$", s) // e.g. 1-3, 2-6
-		selectionMatch, _ := regexp.MatchString(`(\d+)(,\s*\d+)*`, s)     // e.g. 1,3,5
+		renderExecutionTable(executionIds, helpers.SetupTable([]string{"Pipeline", "Execution ID"}))
+	}
 
-		if rangeMatch {
-			pipelinesToRun, err := helpers.ProcessInputRange(s, len(pipelineNames))
-			if err != nil {
-				return err
-			}
+	if wait && len(executionIds) > 0 {
+		return waitForStartedExecutions(ctx, cp, executionIds)
+	}
 
-			runMultiInputPipelines(cp, pipelinesToRun, pipelineMap, executionTable)
+	return nil
+}
 
-		} else if selectionMatch {
-			pipelinesToRun, err := helpers.ProcessInputSelection(s, len(pipelineNames))
-			if err != nil {
-				return err
-			}
+// Waits for the executions started by runPipelines and shows how they finished.
+// Returns an error if any of them did not succeed.
+func waitForStartedExecutions(ctx context.Context, cp *codepipeline.Client, executionIds map[string]string) error {
+	notifier, err := newNotifier()
+	if err != nil {
+		return err
+	}
 
-			// Run pipelines and set up table
-			runMultiInputPipelines(cp, pipelinesToRun, pipelineMap, executionTable)
+	fmt.Println("\nWaiting for the executions to finish...")
+	statuses, err := waitForExecutions(ctx, cp, executionIds, notifier)
+	if err != nil {
+		return err
+	}
 
-		} else {
-			fmt.Println("Input not recognised.")
-			// exit?
+	resultTable := helpers.SetupTable([]string{"Pipeline", "Execution ID", "Status"})
+	var failed []string
+	for _, id := range sortedExecutionIds(executionIds) {
+		if statuses[id] != types.PipelineExecutionStatusSucceeded {
+			failed = append(failed, executionIds[id])
 		}
+		resultTable.Append([]string{executionIds[id], id, string(statuses[id])})
+	}
+	resultTable.Render()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("executions did not succeed (%s)", strings.Join(failed, ", "))
 	}
 
 	return nil
@@ -132,31 +176,140 @@ Enter 'yes' to run all, 'no' to cancel, a number for a specific pipeline, or pro
 
 // Helper function that will render the table to the terminal.
 func renderExecutionTable(executionIds map[string]string, executionTable *tablewriter.Table) {
-	for id, name := range executionIds {
+	for _, id := range sortedExecutionIds(executionIds) {
 		executionTable.Append([]string{
-			name,
+			executionIds[id],
 			id,
 		})
 	}
 	executionTable.Render()
 }
 
-// For range and selection inputs.
-// Takes processed user input and the pipelineMap to run the appropriate pipelines
-// and display the results.
-func runMultiInputPipelines(cp *codepipeline.CodePipeline, pipelinesToRun []int, pipelineMap map[int]string, executionTable *tablewriter.Table) error {
-	fmt.Println("Running pipelines...")
-	executionIds := make(map[string]string)
+// Core logic for running a plan.
+// Each wave is started only once every execution in the previous wave has succeeded.
+// Notable data structures/variables:
+// waves [][]string - pipeline names grouped into waves by plan.Waves.
+// executionIds (map[string]string) - maps execution IDs in the current wave to their pipeline name.
+func runPlan(ctx context.Context, planFile string, opts policyOptions) error {
+	p, err := plan.Load(planFile)
+	if err != nil {
+		return err
+	}
+	waves, err := p.Waves()
+	if err != nil {
+		return err
+	}
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+	notifier, err := newNotifier()
+	if err != nil {
+		return err
+	}
+	pol, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+
+	// Print and confirm the plan
+	fmt.Printf("\n%s\n", "The plan will run the following waves:")
+	for i, wave := range waves {
+		fmt.Printf("    [%v] %s\n", i+1, strings.Join(wave, ", "))
+	}
+
+	s, err := helpers.PromptInput(ctx, "\nDo you want to run this plan? Enter 'yes' to run or 'no' to cancel: ")
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(s, "yes") {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+	var planned []string
+	for _, wave := range waves {
+		planned = append(planned, wave...)
+	}
+	// The plan names its pipelines, so it isn't refused like 'yes' to a search
+	if ok, err := confirmProtected(ctx, cp, policy.Run, planned, false, opts.AllowProtectedBatch); err != nil || !ok {
+		return err
+	}
 
-	for i := range pipelinesToRun {
-		executionId, err := awsutil.RunPipeline(cp, pipelineMap[pipelinesToRun[i]])
+	for i, wave := range waves {
+		// Each wave is checked as it starts, as a blocked window may begin mid-plan
+		details, err := enforcePolicy(ctx, pol, policy.Run, runTargets(wave), opts)
 		if err != nil {
 			return err
 		}
-		executionIds[executionId] = pipelineMap[pipelinesToRun[i]]
+		fmt.Printf("\nRunning wave %v of %v...\n", i+1, len(waves))
+		executionIds, err := runPipelinesAudited(ctx, cp, wave, details)
+		if err != nil {
+			// Executions that started before the error carry on, so say which they were
+			if len(executionIds) > 0 {
+				return fmt.Errorf("wave %v stopped after starting %s: %w", i+1, describeExecutions(executionIds), err)
+			}
+			return err
+		}
+
+		// Wait for every execution in the wave and report the results
+		statuses, err := waitForExecutions(ctx, cp, executionIds, notifier)
+		if err != nil {
+			return err
+		}
+		waveTable := helpers.SetupTable([]string{"Pipeline", "Execution ID", "Status"})
+		var failed []string
+		for _, name := range wave {
+			executionId := executionIdFor(executionIds, name)
+			status := statuses[executionId]
+			if status != types.PipelineExecutionStatusSucceeded {
+				failed = append(failed, name)
+			}
+			waveTable.Append([]string{name, executionId, string(status)})
+		}
+		waveTable.Render()
+
+		if len(failed) > 0 {
+			return fmt.Errorf("wave %v did not succeed (%s), halting plan", i+1, strings.Join(failed, ", "))
+		}
 	}
 
-	renderExecutionTable(executionIds, executionTable)
+	fmt.Println("\nPlan completed successfully.")
 
 	return nil
 }
+
+// Returns the execution IDs sorted by pipeline name
+func sortedExecutionIds(executionIds map[string]string) []string {
+	ids := make([]string, 0, len(executionIds))
+	for id := range executionIds {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if executionIds[ids[i]] != executionIds[ids[j]] {
+			return executionIds[ids[i]] < executionIds[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
+
+// Lists executions as "pipeline (execution ID)", in pipeline order
+func describeExecutions(executionIds map[string]string) string {
+	var started []string
+	for _, id := range sortedExecutionIds(executionIds) {
+		started = append(started, fmt.Sprintf("%s (%s)", executionIds[id], id))
+	}
+	return strings.Join(started, ", ")
+}
+
+// Returns the execution ID started for the given pipeline
+func executionIdFor(executionIds map[string]string, pipelineName string) string {
+	for id, name := range executionIds {
+		if name == pipelineName {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSingle(t *testing.T) {
+	out := runCph(t, "run", "1\n", "run", "--name", "alpha")
+	assertGolden(t, "run_single", out)
+}
+
+func TestRunCancel(t *testing.T) {
+	out := runCph(t, "run_not_started", "no\n", "run")
+	assertGolden(t, "run_cancel", out)
+}
+
+func TestRunDryRun(t *testing.T) {
+	out := runCph(t, "run_not_started", "yes\n", "run", "--name", "beta", "--dry-run")
+	assertGolden(t, "run_dry_run", out)
+}
+
+func TestRunPlan(t *testing.T) {
+	out := runCph(t, "run_plan", "yes\n", "run", "--plan", "testdata/plan.yaml")
+	assertGolden(t, "run_plan", out)
+}
+
+func TestRunWait(t *testing.T) {
+	executionPollInterval = 0
+	t.Cleanup(func() { executionPollInterval = 15 * time.Second })
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	commandOut := filepath.Join(t.TempDir(), "command.out")
+	writeConfig(t, `notifications:
+  - type: slack
+    url: `+server.URL+`
+  - type: command
+    command: echo "$CPH_TRANSITION $CPH_STAGE" >> `+commandOut+`
+    on: [Failed, Succeeded]
+`)
+
+	out, err := runCphErr(t, "run_wait", "1\n", "run", "--wait")
+	if err == nil || err.Error() != "executions did not succeed (alpha)" {
+		t.Errorf("cph run --wait: %v, want alpha to have failed", err)
+	}
+	assertGolden(t, "run_wait", out)
+
+	wantBodies := []string{
+		`{"text":"alpha is waiting for approval of ManualApproval in Approval (execution ` + "`exec-alpha-new`" + `)"}`,
+		`{"text":"alpha failed in Deploy (execution ` + "`exec-alpha-new`" + `)"}`,
+	}
+	if strings.Join(bodies, "\n") != strings.Join(wantBodies, "\n") {
+		t.Errorf("slack got:\n%s\nwant:\n%s", strings.Join(bodies, "\n"), strings.Join(wantBodies, "\n"))
+	}
+	command, err := os.ReadFile(commandOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(command) != "Failed Deploy\n" {
+		t.Errorf("command got %q, want only the failure", command)
+	}
+}
+
+func TestRunPlanPartialWave(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(planFile, []byte("pipelines:\n  - name: api\n  - name: web\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCphErr(t, "run_plan_partial", "yes\n", "run", "--plan", planFile)
+	if err == nil || !strings.HasPrefix(err.Error(), "wave 1 stopped after starting api (exec-api): ") || !strings.Contains(err.Error(), "Pipeline web is disabled") {
+		t.Errorf("cph run --plan: %v, want the started execution in the error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+var (
+	rangeInput     = regexp.MustCompile(`^[0-9]{1,2}-[0-9]{1,2}$`) // e.g. 1-3, 2-6
+	selectionInput = regexp.MustCompile(`^\d+(\s*,\s*\d+)*$`)      // e.g. 1,3,5
+)
+
+// The pipelines chosen from a list
+type pipelineSelection struct {
+	// In the listed order, empty if nothing was chosen
+	Names []string
+	// Whether they were all chosen with 'yes'
+	All bool
+	// Whether 'reject' was entered, which chooses all of them
+	Reject bool
+}
+
+// Prompts the user to choose from the listed pipelines: 'yes' chooses all of
+// them, 'no' cancels, and a number, range or list chooses some of them. With
+// reject set, 'reject' is offered as well.
+func promptPipelineSelection(ctx context.Context, names []string, verb string, reject bool) (pipelineSelection, error) {
+	options := fmt.Sprintf("'yes' to %s all, 'no' to cancel, ", verb)
+	if reject {
+		options += "'reject' to reject all, "
+	}
+	s, err := helpers.PromptInput(ctx, "\n"+fmt.Sprintf(`Do you want to %s these pipelines?
+Enter %sa number for a specific pipeline, or provide a range or list: `, verb, options))
+	if err != nil {
+		return pipelineSelection{}, err
+	}
+
+	if i, err := strconv.Atoi(s); err == nil { // User enters a single number
+		if i < 1 || i > len(names) {
+			return pipelineSelection{}, fmt.Errorf("%v is not one of the listed pipelines", i)
+		}
+		return pipelineSelection{Names: []string{names[i-1]}}, nil
+	}
+	if strings.EqualFold(s, "yes") {
+		return pipelineSelection{Names: names, All: true}, nil
+	}
+	if reject && strings.EqualFold(s, "reject") {
+		return pipelineSelection{Names: names, All: true, Reject: true}, nil
+	}
+	if strings.EqualFold(s, "no") {
+		fmt.Println("Cancelled.")
+		return pipelineSelection{}, nil
+	}
+
+	var selected []int
+	if rangeInput.MatchString(s) {
+		selected, err = helpers.ProcessInputRange(s, len(names))
+	} else if selectionInput.MatchString(s) {
+		selected, err = helpers.ProcessInputSelection(s, len(names))
+	} else {
+		fmt.Println("Input not recognised.")
+		return pipelineSelection{}, nil
+	}
+	if err != nil {
+		return pipelineSelection{}, err
+	}
+
+	var chosen []string
+	for _, i := range selected {
+		chosen = append(chosen, names[i-1])
+	}
+	return pipelineSelection{Names: chosen}, nil
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shreyasrama/cph/pkg/helpers"
+)
+
+func TestPromptPipelineSelection(t *testing.T) {
+	names := []string{"alpha", "beta", "gamma"}
+	tests := []struct {
+		input  string
+		reject bool
+		want   pipelineSelection
+	}{
+		{"2", false, pipelineSelection{Names: []string{"beta"}}},
+		{"yes", false, pipelineSelection{Names: names, All: true}},
+		{"2-3", false, pipelineSelection{Names: []string{"beta", "gamma"}}},
+		{"1, 3", false, pipelineSelection{Names: []string{"alpha", "gamma"}}},
+		{"reject", true, pipelineSelection{Names: names, All: true, Reject: true}},
+		{"reject", false, pipelineSelection{}},
+		{"1,x", false, pipelineSelection{}},
+		{"no", false, pipelineSelection{}},
+	}
+	for _, test := range tests {
+		helpers.SetInput(strings.NewReader(test.input + "\n"))
+		var got pipelineSelection
+		var err error
+		captureStdout(t, func() {
+			got, err = promptPipelineSelection(context.Background(), names, "run", test.reject)
+		})
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("promptPipelineSelection(%q) = %+v, %v, want %+v", test.input, got, err, test.want)
+		}
+	}
+
+	for _, input := range []string{"4", "0-2", "1,4"} {
+		helpers.SetInput(strings.NewReader(input + "\n"))
+		var err error
+		captureStdout(t, func() {
+			_, err = promptPipelineSelection(context.Background(), names, "run", false)
+		})
+		if err == nil {
+			t.Errorf("promptPipelineSelection(%q) chose pipelines that weren't listed", input)
+		}
+	}
+}
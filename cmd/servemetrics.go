@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/exporter"
+)
+
+// serveMetricsCmd represents the serve-metrics command
+var serveMetricsCmd = &cobra.Command{
+	Use:   "serve-metrics",
+	Short: "Serve pipeline health as Prometheus metrics.",
+	Long: `Poll pipelines every --interval and serve their health on /metrics in the
+Prometheus exposition format: the status and duration of the latest execution,
+when the pipeline last succeeded and how many approvals it is waiting for.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			return err
+		}
+		interval, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			return err
+		}
+		name, err := cmd.Flags().GetString("name")
+		if err != nil {
+			return err
+		}
+		once, err := cmd.Flags().GetBool("once")
+		if err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive, got %v", interval)
+		}
+
+		return serveMetrics(cmd.Context(), listen, interval, name, once)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveMetricsCmd)
+
+	serveMetricsCmd.Flags().String("listen", ":9090", "Address to serve metrics on.")
+	serveMetricsCmd.Flags().Duration("interval", time.Minute, "How often pipelines are polled.")
+	serveMetricsCmd.Flags().String("name", "", "Use a name or part of a name to filter the polled pipelines.")
+	serveMetricsCmd.RegisterFlagCompletionFunc("name", completePipelineNames)
+	serveMetricsCmd.Flags().Bool("once", false, "Poll once and print the metrics instead of serving them.")
+}
+
+// Core logic for the serve-metrics feature. Polls until the context is
+// cancelled, then shuts the server down.
+func serveMetrics(ctx context.Context, listen string, interval time.Duration, searchTerm string, once bool) error {
+	// Every poll needs current responses, whatever the cache TTLs are
+	awsutil.SetCacheMode(awsutil.CacheDisabled)
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	exp := exporter.New()
+	poller := newHealthPoller(cp, searchTerm)
+	poll := func() error {
+		health, err := poller.poll(ctx)
+		if health == nil && err != nil {
+			exp.PollFailed()
+			return err
+		}
+		exp.Update(health, time.Now())
+		return err
+	}
+
+	if once {
+		pollErr := poll()
+		if err := exp.Write(os.Stdout); err != nil {
+			return err
+		}
+		return pollErr
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp.Handler())
+	server := &http.Server{Addr: listen, Handler: mux}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics, polling every %v\n", listen, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A failed poll keeps the previous metrics, so keep serving them
+		if err := poll(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error polling pipelines: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		case err := <-serverErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// Polls the health of pipelines, remembering what it has seen of each
+// pipeline's executions so their history is only searched once
+type healthPoller struct {
+	cp         *codepipeline.Client
+	searchTerm string
+	histories  map[string]*executionHistory
+}
+
+// What a poller has seen of a pipeline's executions
+type executionHistory struct {
+	// When the pipeline last succeeded, zero if it never has
+	lastSuccess time.Time
+	// Start time of the oldest execution that could still succeed. Executions
+	// started before it are not listed again.
+	since time.Time
+}
+
+func newHealthPoller(cp *codepipeline.Client, searchTerm string) *healthPoller {
+	return &healthPoller{cp: cp, searchTerm: searchTerm, histories: make(map[string]*executionHistory)}
+}
+
+// Returns the health of every pipeline matching the search term. A pipeline
+// that can't be polled, e.g. as it was deleted or access to it is denied, is
+// marked as failed and the others are still returned, along with an error
+// naming it. Health is nil if the pipelines couldn't be listed.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelines
+// 2. ListPipelineExecutions for each pipeline. The first poll pages back to the last success if the latest execution did not succeed, later ones only list executions since the previous poll.
+// 3. GetPipelineState for each pipeline
+func (hp *healthPoller) poll(ctx context.Context) ([]exporter.PipelineHealth, error) {
+	pipelineNames, err := awsutil.GetPipelineNames(ctx, hp.cp, hp.searchTerm)
+	if err != nil {
+		return nil, err
+	}
+
+	pipelines := make([]exporter.PipelineHealth, 0, len(pipelineNames))
+	var errs []error
+	for _, name := range pipelineNames {
+		health, err := hp.pollPipeline(ctx, name)
+		if err != nil {
+			health = exporter.PipelineHealth{Pipeline: name, PollFailed: true}
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		pipelines = append(pipelines, health)
+	}
+
+	return pipelines, errors.Join(errs...)
+}
+
+// Returns the health of a pipeline
+func (hp *healthPoller) pollPipeline(ctx context.Context, name string) (exporter.PipelineHealth, error) {
+	health := exporter.PipelineHealth{Pipeline: name}
+
+	history, seen := hp.histories[name]
+	var executions []types.PipelineExecutionSummary
+	if !seen {
+		history = &executionHistory{}
+		latest, err := awsutil.GetLatestPipelineExecution(ctx, hp.cp, name)
+		if err != nil {
+			return health, err
+		}
+		if latest != nil {
+			executions = append(executions, *latest)
+			if latest.Status != types.PipelineExecutionStatusSucceeded {
+				succeeded, err := awsutil.GetRecentPipelineExecutions(ctx, hp.cp, name, types.PipelineExecutionStatusSucceeded, 1)
+				if err != nil {
+					return health, err
+				}
+				if len(succeeded) > 0 {
+					history.lastSuccess = aws.ToTime(succeeded[0].LastUpdateTime)
+				}
+			}
+		}
+	} else {
+		var err error
+		executions, err = awsutil.GetPipelineExecutions(ctx, hp.cp, name, history.since)
+		if err != nil {
+			return health, err
+		}
+	}
+
+	for _, execution := range executions {
+		if execution.Status == types.PipelineExecutionStatusSucceeded && aws.ToTime(execution.LastUpdateTime).After(history.lastSuccess) {
+			history.lastSuccess = aws.ToTime(execution.LastUpdateTime)
+		}
+	}
+	if len(executions) > 0 {
+		// Executions are listed newest first
+		history.since = aws.ToTime(executions[0].StartTime)
+		for _, execution := range executions {
+			if execution.Status == types.PipelineExecutionStatusInProgress || execution.Status == types.PipelineExecutionStatusStopping {
+				history.since = aws.ToTime(execution.StartTime)
+			}
+		}
+
+		latest := executions[0]
+		health.Status = string(latest.Status)
+		end := aws.ToTime(latest.LastUpdateTime)
+		if latest.Status == types.PipelineExecutionStatusInProgress {
+			end = time.Now()
+		}
+		health.Duration = end.Sub(aws.ToTime(latest.StartTime))
+	}
+	health.LastSuccess = history.lastSuccess
+
+	approvals, err := awsutil.GetPendingApprovals(ctx, hp.cp, name)
+	if err != nil {
+		return health, err
+	}
+	health.PendingApprovals = len(approvals)
+
+	hp.histories[name] = history
+	return health, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/exporter"
+)
+
+func TestServeMetricsOnce(t *testing.T) {
+	out := runCph(t, "serve_metrics", "", "serve-metrics", "--once")
+
+	// The poll time changes on every run
+	out = regexp.MustCompile(`(?m)^cph_last_poll_timestamp_seconds .*$`).ReplaceAllString(out, "cph_last_poll_timestamp_seconds <time>")
+	assertGolden(t, "serve_metrics", out)
+}
+
+func TestHealthPollerHistory(t *testing.T) {
+	replayFixture(t, "serve_metrics_history")
+	writeConfig(t, "")
+	awsutil.SetCacheMode(awsutil.CacheDisabled)
+	ctx := context.Background()
+
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poller := newHealthPoller(cp, "")
+
+	// The first poll searches back for alpha's last success. gone can't be
+	// polled, which doesn't stop alpha being polled.
+	var health []exporter.PipelineHealth
+	captureStdout(t, func() {
+		health, err = poller.poll(ctx)
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "gone: ") {
+		t.Errorf("got error %v, want one naming gone", err)
+	}
+	if got, want := describeHealth(health), "alpha Failed 1699990900, gone poll failed"; got != want {
+		t.Errorf("first poll got %q, want %q", got, want)
+	}
+
+	// Later polls only list alpha's executions since the previous one
+	captureStdout(t, func() {
+		health, _ = poller.poll(ctx)
+	})
+	if got, want := describeHealth(health), "alpha Succeeded 1700001900, gone poll failed"; got != want {
+		t.Errorf("second poll got %q, want %q", got, want)
+	}
+}
+
+// Describes each pipeline's status and last success, which don't depend on when
+// it was polled
+func describeHealth(pipelines []exporter.PipelineHealth) string {
+	var parts []string
+	for _, p := range pipelines {
+		if p.PollFailed {
+			parts = append(parts, p.Pipeline+" poll failed")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %v", p.Pipeline, p.Status, p.LastSuccess.Unix()))
+	}
+	return strings.Join(parts, ", ")
+}
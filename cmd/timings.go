@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/shreyasrama/cph/pkg/awsutil"
+	"github.com/shreyasrama/cph/pkg/helpers"
+	"github.com/shreyasrama/cph/pkg/metrics"
+)
+
+// timingsCmd represents the timings command
+var timingsCmd = &cobra.Command{
+	Use:   "timings <pipeline>",
+	Short: "Show how long each stage and action of a pipeline takes.",
+	Long: `Show duration percentiles of each stage and action over a pipeline's last
+succeeded executions, along with the time each stage waits before starting.
+Each is compared with the executions before them, so the slowest stages and
+any regressions stand out.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePipelineNameArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		executions, err := cmd.Flags().GetInt("executions")
+		if err != nil {
+			return err
+		}
+		threshold, err := cmd.Flags().GetFloat64("threshold")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		if executions < 1 {
+			return fmt.Errorf("--executions must be at least 1, got %v", executions)
+		}
+
+		return showTimings(cmd.Context(), args[0], executions, threshold, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(timingsCmd)
+
+	timingsCmd.Flags().Int("executions", 10, "Number of succeeded executions to compute timings from, and to compare them with.")
+	timingsCmd.Flags().Float64("threshold", 20, "Percentage a median duration must grow by to be reported as a regression.")
+}
+
+// Number of stages listed as the slowest
+const slowestStageCount = 3
+
+// Core logic for the timings feature.
+// Makes the following calls to CodePipeline:
+// 1. ListPipelineExecutions, until twice --executions succeeded executions are found
+// 2. ListActionExecutions for each of those executions
+func showTimings(ctx context.Context, pipelineName string, count int, threshold float64, output string) error {
+	cp, err := awsutil.CreateCodePipelineClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	summaries, err := awsutil.GetRecentPipelineExecutions(ctx, cp, pipelineName, types.PipelineExecutionStatusSucceeded, 2*count)
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		return fmt.Errorf("pipeline %s has no succeeded executions", pipelineName)
+	}
+
+	var executions []metrics.ExecutionRuns
+	for _, summary := range summaries {
+		runs, err := executionRuns(ctx, cp, pipelineName, summary)
+		if err != nil {
+			return err
+		}
+		executions = append(executions, runs)
+	}
+
+	current := executions
+	var previous []metrics.ExecutionRuns
+	if len(executions) > count {
+		current, previous = executions[:count], executions[count:]
+	}
+	timings := metrics.Timings(current)
+	previousP50 := make(map[string]time.Duration)
+	for _, timing := range metrics.Timings(previous) {
+		previousP50[timing.Stage+"/"+timing.Action] = timing.P50
+	}
+
+	var rows [][]string
+	var regressions []string
+	for _, timing := range timings {
+		wait := ""
+		if timing.Action == "" {
+			wait = formatTiming(timing.WaitP50)
+		}
+
+		before, change := "-", "-"
+		if p50, ok := previousP50[timing.Stage+"/"+timing.Action]; ok && p50 > 0 {
+			before = formatTiming(p50)
+			growth := float64(timing.P50-p50) / float64(p50) * 100
+			change = fmt.Sprintf("%+.0f%%", growth)
+			if growth >= threshold {
+				name := timing.Stage
+				if timing.Action != "" {
+					name += " / " + timing.Action
+				}
+				regressions = append(regressions, fmt.Sprintf("%s %s (%s -> %s)", name, change, before, formatTiming(timing.P50)))
+				if output == "table" {
+					change = color.New(color.FgRed).Sprint(change)
+				}
+			}
+		}
+
+		rows = append(rows, []string{
+			timing.Stage,
+			timing.Action,
+			fmt.Sprint(timing.Runs),
+			formatTiming(timing.P50),
+			formatTiming(timing.P90),
+			formatTiming(timing.Max),
+			wait,
+			before,
+			change,
+		})
+	}
+
+	if output == "table" {
+		fmt.Printf("Timings of the last %v succeeded executions of %s, compared with the %v before them\n\n", len(current), pipelineName, len(previous))
+	}
+	if err := helpers.RenderOutput(output, []string{"Stage", "Action", "Runs", "P50", "P90", "Max", "Wait P50", "Previous P50", "Change"}, rows); err != nil {
+		return err
+	}
+	if output != "table" {
+		return nil
+	}
+
+	var stages []metrics.Timing
+	for _, timing := range timings {
+		if timing.Action == "" {
+			stages = append(stages, timing)
+		}
+	}
+	sort.SliceStable(stages, func(i, j int) bool { return stages[i].P50 > stages[j].P50 })
+	var slowest []string
+	for i := 0; i < len(stages) && i < slowestStageCount; i++ {
+		slowest = append(slowest, fmt.Sprintf("%s (%s)", stages[i].Stage, formatTiming(stages[i].P50)))
+	}
+	fmt.Printf("\nSlowest stages: %s\n", strings.Join(slowest, ", "))
+	if len(regressions) > 0 {
+		fmt.Printf("Regressions of %v%% or more: %s\n", threshold, strings.Join(regressions, ", "))
+	} else if len(previous) > 0 {
+		fmt.Println("No regressions.")
+	}
+
+	return nil
+}
+
+// Returns the action runs of a pipeline execution
+func executionRuns(ctx context.Context, cp *codepipeline.Client, pipelineName string, summary types.PipelineExecutionSummary) (metrics.ExecutionRuns, error) {
+	details, err := awsutil.GetActionExecutions(ctx, cp, pipelineName, aws.ToString(summary.PipelineExecutionId))
+	if err != nil {
+		return metrics.ExecutionRuns{}, err
+	}
+
+	runs := metrics.ExecutionRuns{Start: aws.ToTime(summary.StartTime)}
+	for _, detail := range details {
+		if detail.StartTime == nil || detail.LastUpdateTime == nil {
+			continue
+		}
+		runs.Actions = append(runs.Actions, metrics.ActionRun{
+			Stage:  aws.ToString(detail.StageName),
+			Action: aws.ToString(detail.ActionName),
+			Start:  *detail.StartTime,
+			End:    *detail.LastUpdateTime,
+		})
+	}
+
+	return runs, nil
+}
+
+func formatTiming(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,13 @@
+package cmd
+
+import "testing"
+
+func TestTimings(t *testing.T) {
+	out := runCph(t, "timings", "", "timings", "alpha", "--executions", "2")
+	assertGolden(t, "timings", out)
+}
+
+func TestTimingsCSV(t *testing.T) {
+	out := runCph(t, "timings", "", "timings", "alpha", "--executions", "2", "--threshold", "50", "-o", "csv")
+	assertGolden(t, "timings_csv", out)
+}
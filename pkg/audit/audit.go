@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Environment variable that overrides the location of the audit log
+const LogPathEnv = "CPH_AUDIT_LOG"
+
+// A single action performed by cph, stored as one line of the audit log
+type Record struct {
+	Timestamp   time.Time `json:"timestamp"`
+	CallerArn   string    `json:"callerArn"`
+	Profile     string    `json:"profile"`
+	Region      string    `json:"region"`
+	Command     string    `json:"command"`
+	Pipeline    string    `json:"pipeline"`
+	Stage       string    `json:"stage,omitempty"`
+	ExecutionId string    `json:"executionId,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	// Policy denials that were overridden to perform the action
+	Overrides []string `json:"overrides,omitempty"`
+	Result    string   `json:"result"`
+}
+
+// Returns the path of the audit log, which is $CPH_AUDIT_LOG if set or
+// audit.jsonl in the cph user config directory
+func LogPath() (string, error) {
+	if path := os.Getenv(LogPathEnv); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "cph", "audit.jsonl"), nil
+}
+
+// Appends a record to the audit log, creating the log if needed
+func Append(record Record) error {
+	path, err := LogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+
+	return err
+}
+
+// Returns the records logged between since and until (inclusive). A zero since
+// or until leaves that end of the range open, and an empty pipeline matches
+// every pipeline.
+func Query(since time.Time, until time.Time, pipeline string) ([]Record, error) {
+	path, err := LogPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("could not parse line %v of %s: %w", line, path, err)
+		}
+
+		if !since.IsZero() && record.Timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && record.Timestamp.After(until) {
+			continue
+		}
+		if pipeline != "" && record.Pipeline != pipeline {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
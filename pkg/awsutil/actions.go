@@ -0,0 +1,129 @@
+package awsutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// Given a pipeline name and execution ID, return every action execution in
+// that pipeline execution, most recent first
+func GetActionExecutions(ctx context.Context, client *codepipeline.Client, pipelineName string, executionId string) ([]types.ActionExecutionDetail, error) {
+	params := &codepipeline.ListActionExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+		Filter: &types.ActionExecutionFilter{
+			PipelineExecutionId: aws.String(executionId),
+		},
+	}
+
+	var details []types.ActionExecutionDetail
+	paginator := codepipeline.NewListActionExecutionsPaginator(client, params)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Println("Error listing action executions: ", err)
+			return nil, err
+		}
+		details = append(details, page.ActionExecutionDetails...)
+	}
+
+	return details, nil
+}
+
+// Given a pipeline name, find an action execution in its latest pipeline
+// execution. With an empty stage and action name, the failed action is
+// returned. Otherwise the latest execution of the matching action is returned,
+// whatever its status.
+func FindActionExecution(ctx context.Context, client *codepipeline.Client, pipelineName string, stageName string, actionName string) (*types.ActionExecutionDetail, error) {
+	latest, err := GetLatestPipelineExecution(ctx, client, pipelineName)
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("pipeline %s has never been executed", pipelineName)
+	}
+
+	details, err := GetActionExecutions(ctx, client, pipelineName, *latest.PipelineExecutionId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, detail := range details {
+		if stageName == "" && actionName == "" {
+			if detail.Status == types.ActionExecutionStatusFailed {
+				return &details[i], nil
+			}
+			continue
+		}
+		if stageName != "" && aws.ToString(detail.StageName) != stageName {
+			continue
+		}
+		if actionName != "" && aws.ToString(detail.ActionName) != actionName {
+			continue
+		}
+		return &details[i], nil
+	}
+
+	if stageName == "" && actionName == "" {
+		return nil, fmt.Errorf("no failed action in the latest execution of %s, use --stage or --action to choose one", pipelineName)
+	}
+	return nil, fmt.Errorf("no matching action in the latest execution of %s", pipelineName)
+}
+
+// Given a pipeline name, return its executions that started at or after since,
+// most recent first
+func GetPipelineExecutions(ctx context.Context, client *codepipeline.Client, pipelineName string, since time.Time) ([]types.PipelineExecutionSummary, error) {
+	params := &codepipeline.ListPipelineExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+		MaxResults:   aws.Int32(100),
+	}
+
+	var summaries []types.PipelineExecutionSummary
+	paginator := codepipeline.NewListPipelineExecutionsPaginator(client, params)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Println("Error listing pipeline executions: ", err)
+			return nil, err
+		}
+		for _, summary := range page.PipelineExecutionSummaries {
+			// Executions are listed newest first, so the rest are older still
+			if aws.ToTime(summary.StartTime).Before(since) {
+				return summaries, nil
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries, nil
+}
+
+// Given a pipeline name, return up to count of its most recent executions with
+// the given status, most recent first
+func GetRecentPipelineExecutions(ctx context.Context, client *codepipeline.Client, pipelineName string, status types.PipelineExecutionStatus, count int) ([]types.PipelineExecutionSummary, error) {
+	params := &codepipeline.ListPipelineExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+		MaxResults:   aws.Int32(100),
+	}
+
+	var summaries []types.PipelineExecutionSummary
+	paginator := codepipeline.NewListPipelineExecutionsPaginator(client, params)
+	for paginator.HasMorePages() && len(summaries) < count {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Println("Error listing pipeline executions: ", err)
+			return nil, err
+		}
+		for _, summary := range page.PipelineExecutionSummaries {
+			if summary.Status == status && len(summaries) < count {
+				summaries = append(summaries, summary)
+			}
+		}
+	}
+
+	return summaries, nil
+}
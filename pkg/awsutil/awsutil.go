@@ -1,15 +1,18 @@
 package awsutil
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/codepipeline"
-	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 type StageInfo struct {
@@ -19,25 +22,43 @@ type StageInfo struct {
 	Token      *string
 }
 
-// Create an AWS Session with a Code Pipeline client
-func CreateCodePipelineSession() (*codepipeline.CodePipeline, error) {
-	sess, err := GetSession()
-	return codepipeline.New(sess), err
+// Create a Code Pipeline client
+func CreateCodePipelineClient(ctx context.Context) (*codepipeline.Client, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return codepipeline.NewFromConfig(cfg, func(o *codepipeline.Options) {
+		if url := endpoints.forCodePipeline(); url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+	}), nil
 }
 
-// Create an AWS Session with an STS client
-func CreateSTSSession() (*sts.STS, error) {
-	sess, err := GetSession()
-	return sts.New(sess), err
+// Create an STS client
+func CreateSTSClient(ctx context.Context) (*sts.Client, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if url := endpoints.forSTS(); url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+	}), nil
 }
 
 // Given a search term, return a slice of pipeline names
-func GetPipelineNames(client *codepipeline.CodePipeline, searchTerm string) ([]string, error) {
+func GetPipelineNames(ctx context.Context, client *codepipeline.Client, searchTerm string) ([]string, error) {
 	// List all pipelines
 	params := &codepipeline.ListPipelinesInput{
-		MaxResults: aws.Int64(1000),
+		MaxResults: aws.Int32(1000),
 	}
-	result, err := client.ListPipelines(params)
+	var result *codepipeline.ListPipelinesOutput
+	err := cachedCall(ctx, "ListPipelines", "", &result, func() (err error) {
+		result, err = client.ListPipelines(ctx, params)
+		return err
+	})
 	if err != nil {
 		fmt.Println("Error listing pipelines: ", err)
 		return nil, err
@@ -54,31 +75,41 @@ func GetPipelineNames(client *codepipeline.CodePipeline, searchTerm string) ([]s
 			pipeline_names = append(pipeline_names, *p.Name)
 		}
 	}
+	// Keep listings in the same order between runs, so a number chosen from
+	// one always means the same pipeline
+	sort.Strings(pipeline_names)
 
 	return pipeline_names, nil
 }
 
 // Given a pipeline name, run that pipeline
-func RunPipeline(client *codepipeline.CodePipeline, pipelineName string) (string, error) {
+func RunPipeline(ctx context.Context, client *codepipeline.Client, pipelineName string) (string, error) {
+	if dryRun {
+		recordAction(RecordedAction{Operation: "StartPipelineExecution", Pipeline: pipelineName})
+		return dryRunExecutionIdPrefix + pipelineName, nil
+	}
+
 	// Start pipeline execution
 	params := &codepipeline.StartPipelineExecutionInput{
 		Name: aws.String(pipelineName),
 	}
-	result, err := client.StartPipelineExecution(params)
+	result, err := client.StartPipelineExecution(ctx, params)
 	if err != nil {
 		fmt.Println("Error starting pipeline execution: ", err)
 		return "", err
 	}
+	invalidateCache(ctx, "GetPipelineState", pipelineName)
+	invalidateCache(ctx, "ListPipelineExecutions", pipelineName)
 
 	return *result.PipelineExecutionId, nil
 }
 
 // Given pipeline names, run those pipelines
-func RunPipelines(client *codepipeline.CodePipeline, pipelineNames []string) (map[string]string, error) {
+func RunPipelines(ctx context.Context, client *codepipeline.Client, pipelineNames []string) (map[string]string, error) {
 	// Start pipeline execution
 	m := make(map[string]string)
 	for _, p := range pipelineNames {
-		executionId, err := RunPipeline(client, p)
+		executionId, err := RunPipeline(ctx, client, p)
 		if err != nil {
 			return nil, err
 		}
@@ -88,15 +119,128 @@ func RunPipelines(client *codepipeline.CodePipeline, pipelineNames []string) (ma
 	return m, nil
 }
 
-// Given a pipeline name, return the stage that was last executed
-func GetLastExecutedStage(client *codepipeline.CodePipeline, pipelineName string) (StageInfo, error) {
-	// Get the pipeline state
+// Given a pipeline name and execution ID, return the status of that execution
+func GetPipelineExecutionStatus(ctx context.Context, client *codepipeline.Client, pipelineName string, executionId string) (types.PipelineExecutionStatus, error) {
+	execution, err := GetPipelineExecution(ctx, client, pipelineName, executionId)
+	if err != nil {
+		return "", err
+	}
+
+	return execution.Status, nil
+}
+
+// Given a pipeline name and execution ID, return the execution, including its
+// source revisions and what triggered it
+func GetPipelineExecution(ctx context.Context, client *codepipeline.Client, pipelineName string, executionId string) (*types.PipelineExecution, error) {
+	params := &codepipeline.GetPipelineExecutionInput{
+		PipelineName:        aws.String(pipelineName),
+		PipelineExecutionId: aws.String(executionId),
+	}
+	result, err := client.GetPipelineExecution(ctx, params)
+	if err != nil {
+		fmt.Println("Error retrieving pipeline execution: ", err)
+		return nil, err
+	}
+
+	return result.PipelineExecution, nil
+}
+
+// Given a pipeline name and execution ID, poll the execution until it has
+// finished and return its final status
+func WaitForPipelineExecution(ctx context.Context, client *codepipeline.Client, pipelineName string, executionId string, pollInterval time.Duration) (types.PipelineExecutionStatus, error) {
+	// Dry-run executions were never started, treat them as successful so
+	// that anything waiting on them carries on and records its own calls
+	if IsDryRunExecution(executionId) {
+		return types.PipelineExecutionStatusSucceeded, nil
+	}
+
+	for {
+		status, err := GetPipelineExecutionStatus(ctx, client, pipelineName, executionId)
+		if err != nil {
+			return "", err
+		}
+
+		switch status {
+		case types.PipelineExecutionStatusInProgress, types.PipelineExecutionStatusStopping:
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(pollInterval):
+			}
+		default:
+			return status, nil
+		}
+	}
+}
+
+// Given a pipeline name, return the latest state of its stages and actions
+func GetPipelineState(ctx context.Context, client *codepipeline.Client, pipelineName string) (*codepipeline.GetPipelineStateOutput, error) {
 	params := &codepipeline.GetPipelineStateInput{
 		Name: aws.String(pipelineName),
 	}
-	result, err := client.GetPipelineState(params)
+	var result *codepipeline.GetPipelineStateOutput
+	err := cachedCall(ctx, "GetPipelineState", pipelineName, &result, func() (err error) {
+		result, err = client.GetPipelineState(ctx, params)
+		return err
+	})
 	if err != nil {
 		fmt.Println("Error retrieving pipeline state: ", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// A manual approval waiting for a result
+type PendingApproval struct {
+	StageName   string
+	ActionName  string
+	ExecutionId string
+	Token       string
+	// When the approval started waiting
+	Since time.Time
+}
+
+// Given a pipeline name, return the manual approvals it is waiting for.
+// Always asks AWS rather than the cache, as approvals come and go while waiting.
+func GetPendingApprovals(ctx context.Context, client *codepipeline.Client, pipelineName string) ([]PendingApproval, error) {
+	params := &codepipeline.GetPipelineStateInput{
+		Name: aws.String(pipelineName),
+	}
+	result, err := client.GetPipelineState(ctx, params)
+	if err != nil {
+		fmt.Println("Error retrieving pipeline state: ", err)
+		return nil, err
+	}
+
+	var approvals []PendingApproval
+	for _, stage := range result.StageStates {
+		for _, action := range stage.ActionStates {
+			// Only approvals waiting for a result have a token
+			if action.LatestExecution == nil || action.LatestExecution.Status != types.ActionExecutionStatusInProgress || action.LatestExecution.Token == nil {
+				continue
+			}
+			approval := PendingApproval{
+				StageName:  aws.ToString(stage.StageName),
+				ActionName: aws.ToString(action.ActionName),
+				Token:      aws.ToString(action.LatestExecution.Token),
+				Since:      aws.ToTime(action.LatestExecution.LastStatusChange),
+			}
+			if stage.LatestExecution != nil {
+				approval.ExecutionId = aws.ToString(stage.LatestExecution.PipelineExecutionId)
+			}
+			approvals = append(approvals, approval)
+		}
+	}
+
+	return approvals, nil
+}
+
+// Given a pipeline name, return the stage that was last executed
+func GetLastExecutedStage(ctx context.Context, client *codepipeline.Client, pipelineName string) (StageInfo, error) {
+	result, err := GetPipelineState(ctx, client, pipelineName)
+	if err != nil {
+		return StageInfo{}, err
 	}
 
 	// Iterate over pipeline stage states.
@@ -104,23 +248,23 @@ func GetLastExecutedStage(client *codepipeline.CodePipeline, pipelineName string
 	var stageInfo StageInfo
 	lastStatusChange := time.Date(1970, time.Month(1), 1, 1, 1, 1, 1, time.UTC)
 	for _, p := range result.StageStates {
-		if p.ActionStates[0].LatestExecution != nil {
-			switch *p.ActionStates[0].LatestExecution.Status {
-			case "InProgress", "Failed":
+		if len(p.ActionStates) > 0 && p.ActionStates[0].LatestExecution != nil {
+			switch p.ActionStates[0].LatestExecution.Status {
+			case types.ActionExecutionStatusInProgress, types.ActionExecutionStatusFailed:
 				return StageInfo{
 					*p.ActionStates[0].ActionName,
 					*p.StageName,
-					*p.ActionStates[0].LatestExecution.Status,
+					string(p.ActionStates[0].LatestExecution.Status),
 					p.ActionStates[0].LatestExecution.Token,
 				}, nil
 			default:
-				currentStatusChange := *p.ActionStates[0].LatestExecution.LastStatusChange
+				currentStatusChange := aws.ToTime(p.ActionStates[0].LatestExecution.LastStatusChange)
 				if currentStatusChange.After(lastStatusChange) {
 					lastStatusChange = currentStatusChange
 					stageInfo = StageInfo{
 						*p.ActionStates[0].ActionName,
 						*p.StageName,
-						*p.ActionStates[0].LatestExecution.Status,
+						string(p.ActionStates[0].LatestExecution.Status),
 						p.ActionStates[0].LatestExecution.Token,
 					}
 				}
@@ -131,60 +275,147 @@ func GetLastExecutedStage(client *codepipeline.CodePipeline, pipelineName string
 	return stageInfo, nil
 }
 
-func GetLatestPipelineExecution(client *codepipeline.CodePipeline, pipelineName string) (codepipeline.PipelineExecutionSummary, error) {
+// Given a pipeline name, return its latest execution, or nil if the pipeline
+// has never been executed
+func GetLatestPipelineExecution(ctx context.Context, client *codepipeline.Client, pipelineName string) (*types.PipelineExecutionSummary, error) {
 	// Get one (the latest) pipeline execution
 	params := &codepipeline.ListPipelineExecutionsInput{
-		MaxResults:   aws.Int64(1),
+		MaxResults:   aws.Int32(1),
 		PipelineName: aws.String(pipelineName),
 	}
-	result, err := client.ListPipelineExecutions(params)
+	var result *codepipeline.ListPipelineExecutionsOutput
+	err := cachedCall(ctx, "ListPipelineExecutions", pipelineName, &result, func() (err error) {
+		result, err = client.ListPipelineExecutions(ctx, params)
+		return err
+	})
 	if err != nil {
 		fmt.Println("Error listing pipeline executions: ", err)
-		return codepipeline.PipelineExecutionSummary{}, err
+		return nil, err
+	}
+	if len(result.PipelineExecutionSummaries) == 0 {
+		return nil, nil
 	}
 
-	return *result.PipelineExecutionSummaries[0], nil
+	return &result.PipelineExecutionSummaries[0], nil
 }
 
-func ApprovePipelines(client *codepipeline.CodePipeline, stagesToPutStatus map[string]StageInfo, approvalStatus string) error {
-	svc, err := CreateSTSSession()
+// Given pipeline names mapped to their approval stage, put the approval result
+// for each of them on behalf of the current caller
+func ApprovePipelines(ctx context.Context, client *codepipeline.Client, stagesToPutStatus map[string]StageInfo, approvalStatus types.ApprovalStatus) error {
+	approver, err := GetCallerArn(ctx)
 	if err != nil {
-		fmt.Println("Error creating session: ", err)
-	}
-
-	input := &sts.GetCallerIdentityInput{}
-	callerIdentity, err := svc.GetCallerIdentity(input)
-	if err != nil {
-		fmt.Println("Error getting user: ", err)
+		return err
 	}
 
 	for name, info := range stagesToPutStatus {
-		_, err := client.PutApprovalResult(&codepipeline.PutApprovalResultInput{
-			ActionName:   &info.ActionName,
-			PipelineName: &name,
-			Result: &codepipeline.ApprovalResult{
-				Status:  aws.String(approvalStatus),
-				Summary: aws.String(approvalStatus + " with CPH by " + callerIdentity.String()),
-			},
-			StageName: &info.StageName,
-			Token:     info.Token,
-		})
+		err := ApprovePipeline(ctx, client, name, info, approvalStatus, approver)
 		if err != nil {
-			fmt.Println("Error putting approval result: ", err)
+			return err
 		}
 	}
 
 	return nil
 }
 
-func GetSession() (*session.Session, error) {
-	sess, err := session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable, // Must be set to enable
-		Profile:           os.Getenv("AWS_PROFILE"),
+// Given a pipeline name and its approval stage, put the approval result.
+// The approver is included in the approval summary.
+func ApprovePipeline(ctx context.Context, client *codepipeline.Client, pipelineName string, info StageInfo, approvalStatus types.ApprovalStatus, approver string) error {
+	if dryRun {
+		recordAction(RecordedAction{
+			Operation: "PutApprovalResult",
+			Pipeline:  pipelineName,
+			Stage:     info.StageName,
+			Action:    info.ActionName,
+			Token:     aws.ToString(info.Token),
+			Status:    string(approvalStatus),
+		})
+		return nil
+	}
+
+	_, err := client.PutApprovalResult(ctx, &codepipeline.PutApprovalResultInput{
+		ActionName:   aws.String(info.ActionName),
+		PipelineName: aws.String(pipelineName),
+		Result: &types.ApprovalResult{
+			Status:  approvalStatus,
+			Summary: aws.String(string(approvalStatus) + " with CPH by " + approver),
+		},
+		StageName: aws.String(info.StageName),
+		Token:     info.Token,
 	})
 	if err != nil {
-		fmt.Println("Error creating Session: ", err)
-		return nil, err
+		fmt.Println("Error putting approval result: ", err)
+		return err
+	}
+	invalidateCache(ctx, "GetPipelineState", pipelineName)
+
+	return nil
+}
+
+// Return the ARN of the identity making the calls
+func GetCallerArn(ctx context.Context) (string, error) {
+	svc, err := CreateSTSClient(ctx)
+	if err != nil {
+		return "", err
 	}
-	return sess, err
+
+	callerIdentity, err := svc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		fmt.Println("Error getting user: ", err)
+		return "", err
+	}
+
+	return *callerIdentity.Arn, nil
+}
+
+// Return the region the config has been loaded with
+func GetRegion(ctx context.Context) (string, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.Region, nil
+}
+
+// Sends requests instead of the SDK's default HTTP client when set
+var httpClient aws.HTTPClient
+
+// Sets the HTTP client used to send requests, e.g. to record or replay
+// them in tests. Clients created afterwards use the new HTTP client.
+func SetHTTPClient(client aws.HTTPClient) {
+	httpClient = client
+	loadedConfig = nil
+	cacheScope = ""
+}
+
+// Loaded once per invocation, as resolving credentials (e.g. an SSO token or
+// a credential_process) can be slow
+var loadedConfig *aws.Config
+
+// Load the AWS config for $AWS_PROFILE, or the default profile. Shared config
+// profiles using SSO, assumed roles or credential_process are supported.
+func GetConfig(ctx context.Context) (aws.Config, error) {
+	if loadedConfig != nil {
+		return *loadedConfig, nil
+	}
+
+	options := []func(*config.LoadOptions) error{
+		config.WithRetryer(newRetryer),
+	}
+	if httpClient != nil {
+		options = append(options, config.WithHTTPClient(httpClient))
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		fmt.Println("Error loading AWS config: ", err)
+		return aws.Config{}, err
+	}
+	cfg.HTTPClient = rateLimitedClient{client: cfg.HTTPClient}
+	loadedConfig = &cfg
+
+	return cfg, nil
 }
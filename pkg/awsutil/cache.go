@@ -0,0 +1,143 @@
+package awsutil
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/shreyasrama/cph/pkg/cache"
+)
+
+type CacheMode int
+
+const (
+	// Read responses from the cache when they are fresh, and store new ones
+	CacheEnabled CacheMode = iota
+	// Always call AWS, but store the responses for later calls
+	CacheRefresh
+	// Neither read nor store responses
+	CacheDisabled
+)
+
+// How long responses are cached for, per API. APIs that are not listed
+// here are never cached.
+var CacheTTLs = map[string]time.Duration{
+	"ListPipelines":          10 * time.Minute,
+	"ListPipelineExecutions": 30 * time.Second,
+	"GetPipelineState":       30 * time.Second,
+}
+
+// How long the account a profile belongs to is cached for
+const accountCacheTTL = time.Hour
+
+var cacheMode CacheMode
+
+// Scope (account and region) that cache keys are prefixed with, looked up once
+// per invocation
+var cacheScope string
+
+func SetCacheMode(mode CacheMode) {
+	cacheMode = mode
+}
+
+// Calls the API, unless a fresh response for the same account, region, API and
+// param is cached. out must be a pointer to the variable call stores the
+// response in, so a cached response can be read into it instead.
+func cachedCall(ctx context.Context, api string, param string, out interface{}, call func() error) error {
+	ttl, cacheable := CacheTTLs[api]
+	if !cacheable || cacheMode == CacheDisabled {
+		return call()
+	}
+
+	key, err := cacheKey(ctx, api, param)
+	if err != nil {
+		// Without knowing the account a response can't be cached safely
+		return call()
+	}
+
+	if cacheMode == CacheEnabled {
+		if ok, _ := cache.Get(key, ttl, out); ok {
+			return nil
+		}
+	}
+
+	if err := call(); err != nil {
+		return err
+	}
+	_ = cache.Set(key, out)
+
+	return nil
+}
+
+// Removes a cached response, used when a call changes what it would return
+func invalidateCache(ctx context.Context, api string, param string) {
+	if cacheMode == CacheDisabled {
+		return
+	}
+
+	key, err := cacheKey(ctx, api, param)
+	if err != nil {
+		return
+	}
+	_ = cache.Delete(key)
+}
+
+func cacheKey(ctx context.Context, api string, param string) (string, error) {
+	if cacheScope == "" {
+		region, err := GetRegion(ctx)
+		if err != nil {
+			return "", err
+		}
+		account, err := getAccount(ctx)
+		if err != nil {
+			return "", err
+		}
+		cacheScope = account + "/" + region
+		// Keep responses from stand-in endpoints apart from real ones
+		if url := endpoints.forCodePipeline(); url != "" {
+			cacheScope += "/" + url
+		}
+	}
+
+	return strings.Join([]string{cacheScope, api, param}, "/"), nil
+}
+
+// Returns the account ID of the current credentials. The account is cached as
+// looking it up requires a call to STS, keyed by the access key ID and STS
+// endpoint so that switching credentials, e.g. through environment variables
+// or SSO, or endpoints never reuses another account.
+func getAccount(ctx context.Context) (string, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+	key := strings.Join([]string{"account", os.Getenv("AWS_PROFILE"), creds.AccessKeyID, endpoints.forSTS()}, "/")
+
+	var account string
+	if cacheMode == CacheEnabled {
+		if ok, _ := cache.Get(key, accountCacheTTL, &account); ok {
+			return account, nil
+		}
+	}
+
+	svc, err := CreateSTSClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	callerIdentity, err := svc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	account = aws.ToString(callerIdentity.Account)
+	_ = cache.Set(key, account)
+
+	return account, nil
+}
@@ -0,0 +1,109 @@
+package awsutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/codebuild"
+	cbtypes "github.com/aws/aws-sdk-go-v2/service/codebuild/types"
+)
+
+// Create a CodeBuild client
+func CreateCodeBuildClient(ctx context.Context) (*codebuild.Client, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return codebuild.NewFromConfig(cfg, func(o *codebuild.Options) {
+		if url := endpoints.forCodeBuild(); url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+	}), nil
+}
+
+// Create a CloudWatch Logs client
+func CreateCloudWatchLogsClient(ctx context.Context) (*cloudwatchlogs.Client, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if url := endpoints.forCloudWatchLogs(); url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+	}), nil
+}
+
+// Given a build ID, return the build
+func GetBuild(ctx context.Context, client *codebuild.Client, buildId string) (*cbtypes.Build, error) {
+	result, err := client.BatchGetBuilds(ctx, &codebuild.BatchGetBuildsInput{
+		Ids: []string{buildId},
+	})
+	if err != nil {
+		fmt.Println("Error retrieving build: ", err)
+		return nil, err
+	}
+	if len(result.Builds) == 0 {
+		return nil, fmt.Errorf("build %s not found", buildId)
+	}
+
+	return &result.Builds[0], nil
+}
+
+// Given a build ID, write the build's CloudWatch Logs to w. With follow, new
+// log events keep being written every pollInterval until the build completes.
+func WriteBuildLogs(ctx context.Context, cb *codebuild.Client, logs *cloudwatchlogs.Client, buildId string, follow bool, pollInterval time.Duration, w io.Writer) error {
+	build, err := GetBuild(ctx, cb, buildId)
+	if err != nil {
+		return err
+	}
+	if build.Logs == nil || build.Logs.GroupName == nil || build.Logs.StreamName == nil {
+		return fmt.Errorf("build %s has no CloudWatch Logs", buildId)
+	}
+
+	var nextToken *string
+	for {
+		// Write every event available so far. The forward token stays the same
+		// once the end of the stream has been reached.
+		for {
+			result, err := logs.GetLogEvents(ctx, &cloudwatchlogs.GetLogEventsInput{
+				LogGroupName:  build.Logs.GroupName,
+				LogStreamName: build.Logs.StreamName,
+				StartFromHead: aws.Bool(true),
+				NextToken:     nextToken,
+			})
+			if err != nil {
+				fmt.Println("Error retrieving log events: ", err)
+				return err
+			}
+
+			for _, event := range result.Events {
+				fmt.Fprint(w, aws.ToString(event.Message))
+			}
+
+			if aws.ToString(result.NextForwardToken) == aws.ToString(nextToken) {
+				break
+			}
+			nextToken = result.NextForwardToken
+		}
+
+		if !follow || build.BuildComplete {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+
+		build, err = GetBuild(ctx, cb, buildId)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package awsutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codecommit"
+)
+
+// Create a CodeCommit client
+func CreateCodeCommitClient(ctx context.Context) (*codecommit.Client, error) {
+	cfg, err := GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return codecommit.NewFromConfig(cfg, func(o *codecommit.Options) {
+		if url := endpoints.forCodeCommit(); url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+	}), nil
+}
+
+// Who wrote a commit, as recorded by git
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+// Given a repository name and commit ID, return the commit's author
+func GetCommitAuthor(ctx context.Context, client *codecommit.Client, repositoryName string, commitId string) (CommitAuthor, error) {
+	result, err := client.GetCommit(ctx, &codecommit.GetCommitInput{
+		RepositoryName: aws.String(repositoryName),
+		CommitId:       aws.String(commitId),
+	})
+	if err != nil {
+		fmt.Println("Error retrieving commit: ", err)
+		return CommitAuthor{}, err
+	}
+	if result.Commit == nil || result.Commit.Author == nil {
+		return CommitAuthor{}, nil
+	}
+
+	return CommitAuthor{
+		Name:  aws.ToString(result.Commit.Author.Name),
+		Email: aws.ToString(result.Commit.Author.Email),
+	}, nil
+}
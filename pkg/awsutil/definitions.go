@@ -0,0 +1,103 @@
+package awsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// Given a pipeline name, return its declaration, or nil if there is no such
+// pipeline. A version of 0 returns the current version.
+func GetPipelineDefinition(ctx context.Context, client *codepipeline.Client, pipelineName string, version int32) (*types.PipelineDeclaration, error) {
+	params := &codepipeline.GetPipelineInput{
+		Name: aws.String(pipelineName),
+	}
+	if version > 0 {
+		params.Version = aws.Int32(version)
+	}
+
+	result, err := client.GetPipeline(ctx, params)
+	var notFound *types.PipelineNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		fmt.Println("Error retrieving pipeline: ", err)
+		return nil, err
+	}
+
+	return result.Pipeline, nil
+}
+
+// Given a pipeline declaration, create the pipeline
+func CreatePipeline(ctx context.Context, client *codepipeline.Client, decl *types.PipelineDeclaration) error {
+	if dryRun {
+		recordAction(RecordedAction{Operation: "CreatePipeline", Pipeline: aws.ToString(decl.Name)})
+		return nil
+	}
+
+	_, err := client.CreatePipeline(ctx, &codepipeline.CreatePipelineInput{
+		Pipeline: decl,
+	})
+	if err != nil {
+		fmt.Println("Error creating pipeline: ", err)
+		return err
+	}
+	invalidateCache(ctx, "ListPipelines", "")
+
+	return nil
+}
+
+// Given a pipeline declaration, replace the existing pipeline's declaration with it
+func UpdatePipeline(ctx context.Context, client *codepipeline.Client, decl *types.PipelineDeclaration) error {
+	if dryRun {
+		recordAction(RecordedAction{Operation: "UpdatePipeline", Pipeline: aws.ToString(decl.Name)})
+		return nil
+	}
+
+	_, err := client.UpdatePipeline(ctx, &codepipeline.UpdatePipelineInput{
+		Pipeline: decl,
+	})
+	if err != nil {
+		fmt.Println("Error updating pipeline: ", err)
+		return err
+	}
+	invalidateCache(ctx, "GetPipelineState", aws.ToString(decl.Name))
+
+	return nil
+}
+
+// Given a pipeline name, return its tags by key
+func GetPipelineTags(ctx context.Context, client *codepipeline.Client, pipelineName string) (map[string]string, error) {
+	pipeline, err := client.GetPipeline(ctx, &codepipeline.GetPipelineInput{
+		Name: aws.String(pipelineName),
+	})
+	if err != nil {
+		fmt.Println("Error retrieving pipeline: ", err)
+		return nil, err
+	}
+	if pipeline.Metadata == nil || pipeline.Metadata.PipelineArn == nil {
+		return nil, fmt.Errorf("no ARN returned for pipeline %s", pipelineName)
+	}
+
+	tags := make(map[string]string)
+	paginator := codepipeline.NewListTagsForResourcePaginator(client, &codepipeline.ListTagsForResourceInput{
+		ResourceArn: pipeline.Metadata.PipelineArn,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			fmt.Println("Error listing pipeline tags: ", err)
+			return nil, err
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	return tags, nil
+}
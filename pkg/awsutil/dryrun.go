@@ -0,0 +1,46 @@
+package awsutil
+
+import "strings"
+
+// Prefix of the placeholder execution IDs returned while in dry-run mode
+const dryRunExecutionIdPrefix = "dry-run-"
+
+// A mutating CodePipeline call that would have been made if dry-run mode
+// was not enabled
+type RecordedAction struct {
+	Operation string
+	Pipeline  string
+	Stage     string
+	Action    string
+	Token     string
+	Status    string
+	Reason    string
+}
+
+var dryRun bool
+var recordedActions []RecordedAction
+
+// Enables or disables dry-run mode. While enabled, mutating calls are recorded
+// instead of being sent to AWS. Previously recorded calls are discarded.
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+	recordedActions = nil
+}
+
+func DryRunEnabled() bool {
+	return dryRun
+}
+
+// Returns the calls recorded while in dry-run mode, in the order they were made
+func RecordedActions() []RecordedAction {
+	return recordedActions
+}
+
+func recordAction(action RecordedAction) {
+	recordedActions = append(recordedActions, action)
+}
+
+// Reports whether an execution ID is a placeholder returned in dry-run mode
+func IsDryRunExecution(executionId string) bool {
+	return strings.HasPrefix(executionId, dryRunExecutionIdPrefix)
+}
@@ -0,0 +1,56 @@
+package awsutil
+
+// Endpoint URLs to send requests to instead of AWS, e.g. LocalStack or a stub
+// server. Default applies to every service without its own endpoint. Empty
+// URLs leave the SDK to resolve the endpoint, which also honours the
+// AWS_ENDPOINT_URL environment variables and endpoint_url in the shared config.
+type Endpoints struct {
+	Default        string
+	CodePipeline   string
+	STS            string
+	CodeBuild      string
+	CloudWatchLogs string
+	CodeCommit     string
+}
+
+var endpoints Endpoints
+
+// Sets the endpoints used by every client created afterwards
+func SetEndpoints(e Endpoints) {
+	endpoints = e
+}
+
+func (e Endpoints) forCodePipeline() string {
+	if e.CodePipeline != "" {
+		return e.CodePipeline
+	}
+	return e.Default
+}
+
+func (e Endpoints) forSTS() string {
+	if e.STS != "" {
+		return e.STS
+	}
+	return e.Default
+}
+
+func (e Endpoints) forCodeBuild() string {
+	if e.CodeBuild != "" {
+		return e.CodeBuild
+	}
+	return e.Default
+}
+
+func (e Endpoints) forCloudWatchLogs() string {
+	if e.CloudWatchLogs != "" {
+		return e.CloudWatchLogs
+	}
+	return e.Default
+}
+
+func (e Endpoints) forCodeCommit() string {
+	if e.CodeCommit != "" {
+		return e.CodeCommit
+	}
+	return e.Default
+}
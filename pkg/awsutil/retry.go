@@ -0,0 +1,115 @@
+package awsutil
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"golang.org/x/time/rate"
+)
+
+type RetryOptions struct {
+	// Maximum number of attempts per request, including the first one
+	MaxAttempts int
+	// Bounds of the exponential backoff between attempts
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Maximum number of requests per second across every client, 0 for no limit
+	RequestsPerSecond float64
+}
+
+var retryOptions = RetryOptions{
+	MaxAttempts: 5,
+	MinDelay:    500 * time.Millisecond,
+	MaxDelay:    20 * time.Second,
+}
+
+var limiter *rate.Limiter
+
+// Sets the retry policy and rate limit applied to every client created afterwards
+func SetRetryOptions(opts RetryOptions) {
+	retryOptions = opts
+
+	limiter = nil
+	if opts.RequestsPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RequestsPerSecond), 1)
+	}
+}
+
+// Retries failed and throttled requests using the retry options
+func newRetryer() aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = retryOptions.MaxAttempts
+		o.MaxBackoff = retryOptions.MaxDelay
+		o.Backoff = backoff{min: retryOptions.MinDelay, max: retryOptions.MaxDelay}
+		// Every attempt is already rate limited, don't also fail requests
+		// once the SDK's retry quota runs out
+		o.RateLimiter = ratelimit.None
+	})
+}
+
+// Exponential backoff with full jitter, waiting as long as the service asks
+// for when a response has a Retry-After header
+type backoff struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (b backoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	if after := retryAfter(err); after > 0 {
+		return after, nil
+	}
+
+	ceiling := b.max
+	if attempt < 32 {
+		if d := b.min << uint(attempt); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1)), nil
+}
+
+// Returns the delay requested by a Retry-After header on the error's response,
+// given in either seconds or as an HTTP date
+func retryAfter(err error) time.Duration {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.HTTPResponse() == nil {
+		return 0
+	}
+
+	value := respErr.HTTPResponse().Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+// Waits for the rate limiter before sending each request. Retries go through
+// the HTTP client too, so every attempt is rate limited.
+type rateLimitedClient struct {
+	client aws.HTTPClient
+}
+
+func (c rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	if limiter != nil {
+		if err := limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.client.Do(req)
+}
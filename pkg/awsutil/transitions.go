@@ -0,0 +1,78 @@
+package awsutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// Given a pipeline name, return the names of its stages in order
+func GetStageNames(ctx context.Context, client *codepipeline.Client, pipelineName string) ([]string, error) {
+	result, err := GetPipelineState(ctx, client, pipelineName)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, stage := range result.StageStates {
+		names = append(names, aws.ToString(stage.StageName))
+	}
+
+	return names, nil
+}
+
+// Given a pipeline and stage name, stop artifacts from transitioning into the
+// stage. The reason is shown in the CodePipeline console.
+func DisableStageTransition(ctx context.Context, client *codepipeline.Client, pipelineName string, stageName string, reason string) error {
+	if dryRun {
+		recordAction(RecordedAction{
+			Operation: "DisableStageTransition",
+			Pipeline:  pipelineName,
+			Stage:     stageName,
+			Reason:    reason,
+		})
+		return nil
+	}
+
+	_, err := client.DisableStageTransition(ctx, &codepipeline.DisableStageTransitionInput{
+		PipelineName:   aws.String(pipelineName),
+		StageName:      aws.String(stageName),
+		Reason:         aws.String(reason),
+		TransitionType: types.StageTransitionTypeInbound,
+	})
+	if err != nil {
+		fmt.Println("Error disabling stage transition: ", err)
+		return err
+	}
+	invalidateCache(ctx, "GetPipelineState", pipelineName)
+
+	return nil
+}
+
+// Given a pipeline and stage name, allow artifacts to transition into the stage again
+func EnableStageTransition(ctx context.Context, client *codepipeline.Client, pipelineName string, stageName string) error {
+	if dryRun {
+		recordAction(RecordedAction{
+			Operation: "EnableStageTransition",
+			Pipeline:  pipelineName,
+			Stage:     stageName,
+		})
+		return nil
+	}
+
+	_, err := client.EnableStageTransition(ctx, &codepipeline.EnableStageTransitionInput{
+		PipelineName:   aws.String(pipelineName),
+		StageName:      aws.String(stageName),
+		TransitionType: types.StageTransitionTypeInbound,
+	})
+	if err != nil {
+		fmt.Println("Error enabling stage transition: ", err)
+		return err
+	}
+	invalidateCache(ctx, "GetPipelineState", pipelineName)
+
+	return nil
+}
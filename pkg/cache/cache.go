@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A cached value and the time it was stored
+type entry struct {
+	StoredAt time.Time       `json:"storedAt"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// Returns the directory cache entries are stored in, which is cph in the
+// user cache directory (e.g. ~/.cache/cph)
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "cph"), nil
+}
+
+// Reads the value stored under key into v. Returns false if there is no
+// entry for key or it is older than ttl.
+func Get(key string, ttl time.Duration, v interface{}) (bool, error) {
+	path, err := entryPath(key)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		// Treat unreadable entries as missing, they are overwritten on the next Set
+		return false, nil
+	}
+	if time.Since(e.StoredAt) > ttl {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(e.Value, v)
+}
+
+// Stores v under key
+func Set(key string, v interface{}) error {
+	path, err := entryPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{StoredAt: time.Now(), Value: value})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Returns the file an entry is stored in. Keys are hashed, as they can hold
+// characters that aren't safe in file names, and no two keys may share a file.
+func entryPath(key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// Removes the entry stored under key, if there is one
+func Delete(key string) error {
+	path, err := entryPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Removes every cache entry
+func Clear() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
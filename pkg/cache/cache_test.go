@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeysDontCollide(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if err := Set("123456789012/us-east-1/GetPipelineState/app@prod", "at"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set("123456789012/us-east-1/GetPipelineState/app_prod", "underscore"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	if ok, err := Get("123456789012/us-east-1/GetPipelineState/app@prod", time.Minute, &got); !ok || err != nil || got != "at" {
+		t.Errorf("Get() = %q, %v, %v, want the value stored under the same key", got, ok, err)
+	}
+}
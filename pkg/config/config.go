@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variable that overrides the location of the config file
+const PathEnv = "CPH_CONFIG"
+
+// Settings read from the cph config file. Example config file:
+//
+//	retry:
+//	  maxAttempts: 5
+//	  minDelay: 500ms
+//	  maxDelay: 20s
+//	rateLimit: 10
+//	endpoints:
+//	  default: http://localhost:4566
+//	  sts: http://localhost:4567
+//	lint:
+//	  prodPattern: "-prod$"
+//	  rules:
+//	    encrypted-artifact-store: warning
+//	  custom:
+//	    - name: team-build-projects
+//	      severity: error
+//	      message: CodeBuild projects must belong to the team
+//	      provider: CodeBuild
+//	      config: ProjectName
+//	      pattern: "^team-"
+//	notifications:
+//	  - type: slack
+//	    url: https://hooks.slack.com/services/T000/B000/XXXX
+//	  - type: command
+//	    command: say "$CPH_MESSAGE"
+//	    on: [Failed, Succeeded]
+//	policy: /etc/cph/policy.yaml
+//	protected:
+//	  pipelines: ["-prod$"]
+//	  tags:
+//	    env: prod
+type Config struct {
+	Retry Retry `yaml:"retry"`
+	// Maximum number of AWS API requests per second, 0 for no limit
+	RateLimit float64   `yaml:"rateLimit"`
+	Endpoints Endpoints `yaml:"endpoints"`
+	Lint      Lint      `yaml:"lint"`
+	// Where to send notifications while waiting on executions
+	Notifications []Notification `yaml:"notifications"`
+	// Path of the policy file checked before pipelines are run or approved
+	Policy    string    `yaml:"policy"`
+	Protected Protected `yaml:"protected"`
+}
+
+type Retry struct {
+	// Maximum number of attempts per request, including the first one
+	MaxAttempts int `yaml:"maxAttempts"`
+	// Bounds of the exponential backoff between attempts
+	MinDelay time.Duration `yaml:"minDelay"`
+	MaxDelay time.Duration `yaml:"maxDelay"`
+}
+
+// Endpoint URLs to send requests to instead of AWS, e.g. LocalStack.
+// Default applies to every service without its own endpoint.
+type Endpoints struct {
+	Default        string `yaml:"default"`
+	CodePipeline   string `yaml:"codepipeline"`
+	STS            string `yaml:"sts"`
+	CodeBuild      string `yaml:"codebuild"`
+	CloudWatchLogs string `yaml:"logs"`
+	CodeCommit     string `yaml:"codecommit"`
+}
+
+// Settings for cph lint
+type Lint struct {
+	// Regular expression matching the names of production pipelines
+	ProdPattern string `yaml:"prodPattern"`
+	// Severity of built-in rules by name: error, warning, info or off
+	Rules map[string]string `yaml:"rules"`
+	// Rules added to the built-in ones
+	Custom []LintRule `yaml:"custom"`
+}
+
+// A rule requiring an action configuration key to match a pattern. The
+// pipelines, stage, provider and category fields limit which actions the rule
+// applies to, and are regular expressions except for category and provider.
+type LintRule struct {
+	Name      string `yaml:"name"`
+	Severity  string `yaml:"severity"`
+	Message   string `yaml:"message"`
+	Pipelines string `yaml:"pipelines"`
+	Stage     string `yaml:"stage"`
+	Category  string `yaml:"category"`
+	Provider  string `yaml:"provider"`
+	Config    string `yaml:"config"`
+	Pattern   string `yaml:"pattern"`
+}
+
+// A notification sink
+type Notification struct {
+	// webhook, slack, desktop or command
+	Type string `yaml:"type"`
+	// URL of the webhook or Slack incoming webhook
+	URL string `yaml:"url"`
+	// Go template of the webhook's JSON body, the event as JSON if empty
+	Template string            `yaml:"template"`
+	Headers  map[string]string `yaml:"headers"`
+	// Shell command to run, given the event in CPH_* environment variables
+	Command string `yaml:"command"`
+	// Transitions to notify about, Failed and WaitingForApproval if empty
+	On []string `yaml:"on"`
+}
+
+// Pipelines that need their name, or how many there are, typed to confirm
+// running or approving them. A pipeline is protected if its name matches any of
+// the patterns or it has any of the tags.
+type Protected struct {
+	// Regular expressions matching the names of protected pipelines
+	Pipelines []string `yaml:"pipelines"`
+	// Tags marking protected pipelines, e.g. env: prod
+	Tags map[string]string `yaml:"tags"`
+}
+
+// Returns the settings used when they are not set in the config file
+func Default() Config {
+	return Config{
+		Retry: Retry{
+			MaxAttempts: 5,
+			MinDelay:    500 * time.Millisecond,
+			MaxDelay:    20 * time.Second,
+		},
+		RateLimit: 10,
+		Lint: Lint{
+			ProdPattern: "prod",
+		},
+	}
+}
+
+// Returns the path of the config file, which is $CPH_CONFIG if set or
+// config.yaml in the cph user config directory
+func Path() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "cph", "config.yaml"), nil
+}
+
+// Reads the config file on top of the default settings. A missing config file
+// is not an error.
+func Load() (Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,202 @@
+package definition
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a definition can be written in
+var Formats = []string{"yaml", "json"}
+
+// Returns the pipeline declaration in the shape used by the CodePipeline API
+// and the AWS CLI, i.e. with camelCase keys and without unset fields. The
+// version is left out, as it is metadata that changes on every update.
+func ToMap(decl *types.PipelineDeclaration) map[string]interface{} {
+	m, _ := toAPIShape(reflect.ValueOf(decl)).(map[string]interface{})
+	delete(m, "version")
+	return m
+}
+
+// Converts an SDK value to plain maps, slices and scalars. Struct field names
+// are converted to API names, map keys (e.g. action configuration) are kept.
+func toAPIShape(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toAPIShape(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			// Enums and other non-pointer fields are unset when zero
+			if kind := field.Type.Kind(); kind != reflect.Ptr && kind != reflect.Slice && kind != reflect.Map && v.Field(i).IsZero() {
+				continue
+			}
+			if value := toAPIShape(v.Field(i)); value != nil {
+				m[apiName(field.Name)] = value
+			}
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = toAPIShape(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{})
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = toAPIShape(iter.Value())
+		}
+		return m
+	case reflect.String:
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
+
+// Converts an SDK field name to its API name, e.g. RoleArn to roleArn
+func apiName(field string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[size:]
+}
+
+// Returns the definition as a file in the given format. Like the AWS CLI, the
+// declaration is wrapped in a "pipeline" key, so the file can also be used
+// with aws codepipeline create-pipeline --cli-input-json.
+func Marshal(decl *types.PipelineDeclaration, format string) ([]byte, error) {
+	file := map[string]interface{}{"pipeline": ToMap(decl)}
+
+	switch format {
+	case "yaml":
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(file); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("invalid format %q, must be one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Reads a definition file written by Marshal or the AWS CLI. YAML and JSON
+// files are both accepted, and any metadata in the file is ignored.
+func Load(path string) (*types.PipelineDeclaration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decl, err := Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filepath.Base(path), err)
+	}
+
+	return decl, nil
+}
+
+// Parses a definition file's contents, see Load
+func Unmarshal(data []byte) (*types.PipelineDeclaration, error) {
+	// JSON is valid YAML, so one parser reads both
+	var file map[string]interface{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	pipeline, ok := file["pipeline"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no pipeline declaration found")
+	}
+	stringifyConfiguration(pipeline)
+
+	// encoding/json matches field names case-insensitively, so the API shape
+	// can be read straight into the SDK types
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var decl types.PipelineDeclaration
+	if err := json.Unmarshal(data, &decl); err != nil {
+		return nil, err
+	}
+	if decl.Name == nil || *decl.Name == "" {
+		return nil, fmt.Errorf("the pipeline declaration has no name")
+	}
+
+	return &decl, nil
+}
+
+// Action configuration values are always strings in the API, but edited YAML
+// often leaves them unquoted, e.g. PollForSourceChanges: false, so scalars are
+// turned back into strings before they're decoded into the SDK types
+func stringifyConfiguration(pipeline map[string]interface{}) {
+	for _, stage := range fieldList(pipeline, "stages") {
+		for _, action := range fieldList(stage, "actions") {
+			config, ok := field(action, "configuration").(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, value := range config {
+				switch value.(type) {
+				case bool, int, int64, uint64, float64:
+					config[key] = fmt.Sprint(value)
+				}
+			}
+		}
+	}
+}
+
+// Returns the maps in a list field of m, if it is one
+func fieldList(m map[string]interface{}, name string) []map[string]interface{} {
+	list, _ := field(m, name).([]interface{})
+	var maps []map[string]interface{}
+	for _, item := range list {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, itemMap)
+		}
+	}
+	return maps
+}
+
+// Returns a field of m, matching its name case-insensitively like encoding/json
+func field(m map[string]interface{}, name string) interface{} {
+	if value, ok := m[name]; ok {
+		return value
+	}
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package definition
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+func testDeclaration() *types.PipelineDeclaration {
+	return &types.PipelineDeclaration{
+		Name:    aws.String("alpha"),
+		RoleArn: aws.String("arn:aws:iam::123456789012:role/pipeline"),
+		Version: aws.Int32(3),
+		Stages: []types.StageDeclaration{{
+			Name: aws.String("Source"),
+			Actions: []types.ActionDeclaration{{
+				Name:          aws.String("Source"),
+				RunOrder:      aws.Int32(1),
+				Configuration: map[string]string{"BranchName": "main"},
+			}},
+		}},
+	}
+}
+
+func TestToMap(t *testing.T) {
+	got := ToMap(testDeclaration())
+	want := map[string]interface{}{
+		"name":    "alpha",
+		"roleArn": "arn:aws:iam::123456789012:role/pipeline",
+		"stages": []interface{}{map[string]interface{}{
+			"name": "Source",
+			"actions": []interface{}{map[string]interface{}{
+				"name":          "Source",
+				"runOrder":      int32(1),
+				"configuration": map[string]interface{}{"BranchName": "main"},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %#v, want %#v", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		data, err := Marshal(testDeclaration(), format)
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", format, err)
+		}
+		decl, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal(%s): %v", format, err)
+		}
+		if changes := Diff(ToMap(testDeclaration()), ToMap(decl)); len(changes) != 0 {
+			t.Errorf("%s round trip changed the definition: %v", format, changes)
+		}
+	}
+}
+
+func TestUnmarshalUnquotedConfiguration(t *testing.T) {
+	data := `pipeline:
+  name: alpha
+  stages:
+    - name: Source
+      actions:
+        - name: Source
+          configuration:
+            BranchName: main
+            PollForSourceChanges: false
+            BatchSize: 10
+            Ratio: 0.5
+`
+	decl, err := Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"BranchName": "main", "PollForSourceChanges": "false", "BatchSize": "10", "Ratio": "0.5"}
+	if got := decl.Stages[0].Actions[0].Configuration; !reflect.DeepEqual(got, want) {
+		t.Errorf("configuration = %v, want %v", got, want)
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, data := range []string{"name: alpha", "pipeline:\n  roleArn: arn", "{"} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Errorf("Unmarshal(%q) = nil error, want an error", data)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changed := testDeclaration()
+	changed.Stages[0].Actions[0].Configuration["BranchName"] = "release"
+	changed.Stages = append(changed.Stages, types.StageDeclaration{Name: aws.String("Deploy")})
+	changed.RoleArn = nil
+
+	var got []string
+	for _, change := range Diff(ToMap(testDeclaration()), ToMap(changed)) {
+		got = append(got, change.Path)
+	}
+	want := []string{"roleArn", "stages[Source].actions[Source].configuration.BranchName", "stages[Deploy]"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Diff() paths = %v, want %v", got, want)
+	}
+}
+
+func TestDiffActions(t *testing.T) {
+	changed := testDeclaration()
+	changed.Stages[0].Actions = append(changed.Stages[0].Actions, types.ActionDeclaration{Name: aws.String("Lint")})
+
+	changes := Diff(ToMap(testDeclaration()), ToMap(changed))
+	if len(changes) != 1 {
+		t.Fatalf("Diff() = %v, want one change", changes)
+	}
+	change := changes[0]
+	if change.Path != "stages[Source].actions[Lint]" || change.Stage != "Source" || change.Action != "Lint" || change.Kind() != "added" {
+		t.Errorf("Diff() = %+v, want Lint added to Source", change)
+	}
+}
+
+func TestDiffStageOrder(t *testing.T) {
+	old := testDeclaration()
+	old.Stages = append(old.Stages, types.StageDeclaration{Name: aws.String("Deploy")})
+	reordered := testDeclaration()
+	reordered.Stages = append([]types.StageDeclaration{{Name: aws.String("Deploy")}}, reordered.Stages...)
+
+	changes := Diff(ToMap(old), ToMap(reordered))
+	if len(changes) != 1 || changes[0].Path != "stages" || changes[0].Kind() != "changed" {
+		t.Fatalf("Diff() = %v, want the stage order to change", changes)
+	}
+	if !reflect.DeepEqual(changes[0].New, []interface{}{"Deploy", "Source"}) {
+		t.Errorf("new stage order = %v, want [Deploy Source]", changes[0].New)
+	}
+}
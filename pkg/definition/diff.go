@@ -0,0 +1,230 @@
+package definition
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A difference between two definitions. Old is nil for an added value and New
+// is nil for a removed one. Stage and Action name the stage and action the
+// difference is in, if any.
+type Change struct {
+	Path   string
+	Stage  string
+	Action string
+	Old    interface{}
+	New    interface{}
+}
+
+// Returns whether the change is an addition, removal or change
+func (c Change) Kind() string {
+	switch {
+	case c.Old == nil:
+		return "added"
+	case c.New == nil:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// Returns the differences between two definitions in the shape returned by
+// ToMap. Stages and actions are matched by name rather than position, so
+// adding a stage or action is reported as one change. A change in the order
+// of stages is reported with the path "stages".
+func Diff(old map[string]interface{}, new map[string]interface{}) []Change {
+	var changes []Change
+	diffValues(Change{}, old, new, &changes)
+	return changes
+}
+
+// Compares two values, reporting differences under the path and location in at
+func diffValues(at Change, old interface{}, new interface{}, changes *[]Change) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		for _, key := range sortedKeys(oldMap, newMap) {
+			child := at
+			child.Path = joinPath(at.Path, key)
+			if (key == "stages" && at.Stage == "") || (key == "actions" && at.Stage != "" && at.Action == "") {
+				if diffNamed(child, key, oldMap[key], newMap[key], changes) {
+					continue
+				}
+			}
+			diffValues(child, oldMap[key], newMap[key], changes)
+		}
+		return
+	}
+
+	oldSlice, oldIsSlice := old.([]interface{})
+	newSlice, newIsSlice := new.([]interface{})
+	if oldIsSlice && newIsSlice {
+		for i := 0; i < len(oldSlice) || i < len(newSlice); i++ {
+			var o, n interface{}
+			if i < len(oldSlice) {
+				o = oldSlice[i]
+			}
+			if i < len(newSlice) {
+				n = newSlice[i]
+			}
+			child := at
+			child.Path = fmt.Sprintf("%s[%v]", at.Path, i)
+			diffValues(child, o, n, changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(normaliseValue(old), normaliseValue(new)) {
+		at.Old, at.New = old, new
+		*changes = append(*changes, at)
+	}
+}
+
+// Compares lists of stages or actions by name. Returns false if either list is
+// not a list of named items, so it can be compared by position instead.
+func diffNamed(at Change, key string, old interface{}, new interface{}, changes *[]Change) bool {
+	oldNames, oldItems, ok := namedItems(old)
+	if !ok {
+		return false
+	}
+	newNames, newItems, ok := namedItems(new)
+	if !ok {
+		return false
+	}
+
+	// Only stages run in list order, actions are ordered by runOrder
+	if key == "stages" {
+		oldOrder := commonNames(oldNames, newItems)
+		newOrder := commonNames(newNames, oldItems)
+		if !reflect.DeepEqual(oldOrder, newOrder) {
+			order := at
+			order.Old, order.New = toInterfaces(oldOrder), toInterfaces(newOrder)
+			*changes = append(*changes, order)
+		}
+	}
+
+	for _, name := range mergeNames(oldNames, newNames) {
+		child := at
+		child.Path = fmt.Sprintf("%s[%s]", at.Path, name)
+		if key == "stages" {
+			child.Stage = name
+		} else {
+			child.Action = name
+		}
+
+		oldItem, inOld := oldItems[name]
+		newItem, inNew := newItems[name]
+		if inOld && inNew {
+			diffValues(child, oldItem, newItem, changes)
+			continue
+		}
+		if inOld {
+			child.Old = oldItem
+		} else {
+			child.New = newItem
+		}
+		*changes = append(*changes, child)
+	}
+
+	return true
+}
+
+// Returns the names of a list of named maps in order, and the maps by name
+func namedItems(v interface{}) ([]string, map[string]interface{}, bool) {
+	if v == nil {
+		return nil, map[string]interface{}{}, true
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, nil, false
+	}
+
+	var names []string
+	items := make(map[string]interface{})
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || items[name] != nil {
+			return nil, nil, false
+		}
+		names = append(names, name)
+		items[name] = m
+	}
+
+	return names, items, true
+}
+
+// Returns the names that are also in other, in order
+func commonNames(names []string, other map[string]interface{}) []string {
+	var common []string
+	for _, name := range names {
+		if _, ok := other[name]; ok {
+			common = append(common, name)
+		}
+	}
+	return common
+}
+
+// Returns the old names in order followed by the names only in new, so
+// removed and added items are reported where they were or will be
+func mergeNames(oldNames []string, newNames []string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, name := range append(append([]string{}, oldNames...), newNames...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+func sortedKeys(a map[string]interface{}, b map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func toInterfaces(s []string) []interface{} {
+	result := make([]interface{}, len(s))
+	for i, v := range s {
+		result[i] = v
+	}
+	return result
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Numbers read from a file and from the SDK have different types, so compare
+// them by value
+func normaliseValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
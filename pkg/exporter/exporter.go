@@ -0,0 +1,217 @@
+package exporter
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Execution statuses reported by the status gauge. Exactly one of them is 1
+// for each pipeline that has been executed.
+var Statuses = []string{"InProgress", "Stopped", "Stopping", "Succeeded", "Superseded", "Failed", "Cancelled"}
+
+// The health of a pipeline at the time it was polled
+type PipelineHealth struct {
+	Pipeline string
+	// Status of the latest execution, empty if the pipeline has never run
+	Status string
+	// How long the latest execution took, or has taken so far
+	Duration time.Duration
+	// When the latest succeeded execution finished, zero if there is none
+	LastSuccess      time.Time
+	PendingApprovals int
+	// The pipeline couldn't be polled, so the rest is unknown
+	PollFailed bool
+}
+
+// Exposes pipeline health as Prometheus metrics
+type Exporter struct {
+	registry   *prometheus.Registry
+	pipelines  *pipelineCollector
+	polls      prometheus.Counter
+	pollErrors prometheus.Counter
+
+	// Health of each pipeline as of the last time it was polled successfully
+	previous map[string]PipelineHealth
+}
+
+// Returns an Exporter with its metrics registered
+func New() *Exporter {
+	e := &Exporter{
+		registry:  prometheus.NewRegistry(),
+		pipelines: newPipelineCollector(),
+		polls: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cph_polls_total",
+			Help: "Number of times pipelines have been polled.",
+		}),
+		pollErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cph_poll_errors_total",
+			Help: "Number of polls that failed.",
+		}),
+		previous: make(map[string]PipelineHealth),
+	}
+	e.registry.MustRegister(e.pipelines, e.polls, e.pollErrors)
+
+	return e
+}
+
+// Replaces the pipeline metrics with the results of a successful poll.
+// Pipelines missing from the poll, e.g. deleted ones, are no longer reported.
+// Pipelines that failed to poll keep the metrics of their last successful poll.
+func (e *Exporter) Update(pipelines []PipelineHealth, polledAt time.Time) {
+	snapshot := make([]pipelineSample, 0, len(pipelines))
+	current := make(map[string]PipelineHealth)
+	for _, p := range pipelines {
+		sample := pipelineSample{health: p, known: true}
+		if p.PollFailed {
+			sample.health, sample.known = e.previous[p.Pipeline]
+			sample.health.Pipeline = p.Pipeline
+			sample.failed = true
+		}
+		if sample.known {
+			current[p.Pipeline] = sample.health
+		}
+		snapshot = append(snapshot, sample)
+	}
+	e.previous = current
+
+	e.pipelines.swap(snapshot, polledAt)
+	e.polls.Inc()
+}
+
+// Records a failed poll. The metrics of the last successful poll are kept.
+func (e *Exporter) PollFailed() {
+	e.polls.Inc()
+	e.pollErrors.Inc()
+}
+
+// Returns a handler serving the metrics in the Prometheus exposition format
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Writes the metrics in the Prometheus text format
+func (e *Exporter) Write(w io.Writer) error {
+	families, err := e.registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	encoder := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The metrics reported for one pipeline
+type pipelineSample struct {
+	// From this poll, or the last successful one if this one failed
+	health PipelineHealth
+	// Whether health is known, which it isn't if the pipeline has never been
+	// polled successfully
+	known  bool
+	failed bool
+}
+
+// Collects the pipeline metrics from the results of the last poll. The
+// results are swapped in whole, so a scrape never sees a poll half applied.
+type pipelineCollector struct {
+	status           *prometheus.Desc
+	duration         *prometheus.Desc
+	lastSuccess      *prometheus.Desc
+	pendingApprovals *prometheus.Desc
+	pollFailed       *prometheus.Desc
+	lastPoll         *prometheus.Desc
+
+	mu        sync.Mutex
+	pipelines []pipelineSample
+	polledAt  time.Time
+}
+
+func newPipelineCollector() *pipelineCollector {
+	return &pipelineCollector{
+		status: prometheus.NewDesc("cph_pipeline_status",
+			"Status of the latest execution of the pipeline, 1 for the current status and 0 otherwise.",
+			[]string{"pipeline", "status"}, nil),
+		duration: prometheus.NewDesc("cph_pipeline_execution_duration_seconds",
+			"How long the latest execution of the pipeline took, or has taken so far.",
+			[]string{"pipeline"}, nil),
+		lastSuccess: prometheus.NewDesc("cph_pipeline_last_success_timestamp_seconds",
+			"When the latest succeeded execution of the pipeline finished, as a Unix timestamp.",
+			[]string{"pipeline"}, nil),
+		pendingApprovals: prometheus.NewDesc("cph_pipeline_pending_approvals",
+			"Number of manual approvals the pipeline is waiting for.",
+			[]string{"pipeline"}, nil),
+		pollFailed: prometheus.NewDesc("cph_pipeline_poll_failed",
+			"1 if the pipeline could not be polled, in which case its other metrics are from the last successful poll, and 0 otherwise.",
+			[]string{"pipeline"}, nil),
+		lastPoll: prometheus.NewDesc("cph_last_poll_timestamp_seconds",
+			"When pipelines were last polled successfully, as a Unix timestamp.",
+			nil, nil),
+	}
+}
+
+// Replaces the results of the last poll
+func (c *pipelineCollector) swap(pipelines []pipelineSample, polledAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pipelines = pipelines
+	c.polledAt = polledAt
+}
+
+func (c *pipelineCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.status
+	ch <- c.duration
+	ch <- c.lastSuccess
+	ch <- c.pendingApprovals
+	ch <- c.pollFailed
+	ch <- c.lastPoll
+}
+
+func (c *pipelineCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	pipelines, polledAt := c.pipelines, c.polledAt
+	c.mu.Unlock()
+
+	if polledAt.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.lastPoll, prometheus.GaugeValue, float64(polledAt.Unix()))
+
+	for _, sample := range pipelines {
+		p := sample.health
+		failed := 0.0
+		if sample.failed {
+			failed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.pollFailed, prometheus.GaugeValue, failed, p.Pipeline)
+		if !sample.known {
+			continue
+		}
+
+		if p.Status != "" {
+			for _, status := range Statuses {
+				value := 0.0
+				if status == p.Status {
+					value = 1
+				}
+				ch <- prometheus.MustNewConstMetric(c.status, prometheus.GaugeValue, value, p.Pipeline, status)
+			}
+			ch <- prometheus.MustNewConstMetric(c.duration, prometheus.GaugeValue, p.Duration.Seconds(), p.Pipeline)
+		}
+		if !p.LastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastSuccess, prometheus.GaugeValue, float64(p.LastSuccess.Unix()), p.Pipeline)
+		}
+		ch <- prometheus.MustNewConstMetric(c.pendingApprovals, prometheus.GaugeValue, float64(p.PendingApprovals), p.Pipeline)
+	}
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExporter(t *testing.T) {
+	e := New()
+	e.Update([]PipelineHealth{
+		{Pipeline: "alpha", Status: "Failed", Duration: 90 * time.Second, LastSuccess: time.Unix(1700000000, 0), PendingApprovals: 1},
+		{Pipeline: "fresh"},
+	}, time.Unix(1700000500, 0))
+	e.PollFailed()
+
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`cph_pipeline_status{pipeline="alpha",status="Failed"} 1`,
+		`cph_pipeline_status{pipeline="alpha",status="Succeeded"} 0`,
+		`cph_pipeline_execution_duration_seconds{pipeline="alpha"} 90`,
+		`cph_pipeline_last_success_timestamp_seconds{pipeline="alpha"} 1.7e+09`,
+		`cph_pipeline_pending_approvals{pipeline="alpha"} 1`,
+		`cph_pipeline_pending_approvals{pipeline="fresh"} 0`,
+		`cph_polls_total 2`,
+		`cph_poll_errors_total 1`,
+		`cph_last_poll_timestamp_seconds 1.7000005e+09`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), `cph_pipeline_status{pipeline="fresh"`) {
+		t.Errorf("metrics contain a status for a pipeline that never ran:\n%s", body)
+	}
+}
+
+func TestExporterPollFailed(t *testing.T) {
+	e := New()
+	e.Update([]PipelineHealth{{Pipeline: "alpha", Status: "Succeeded", PendingApprovals: 2}}, time.Unix(1700000000, 0))
+	e.Update([]PipelineHealth{{Pipeline: "alpha", PollFailed: true}, {Pipeline: "gone", PollFailed: true}}, time.Unix(1700000060, 0))
+
+	var b strings.Builder
+	if err := e.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`cph_pipeline_status{pipeline="alpha",status="Succeeded"} 1`,
+		`cph_pipeline_pending_approvals{pipeline="alpha"} 2`,
+		`cph_pipeline_poll_failed{pipeline="alpha"} 1`,
+		`cph_pipeline_poll_failed{pipeline="gone"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), `cph_pipeline_pending_approvals{pipeline="gone"}`) {
+		t.Errorf("metrics report a pipeline that has never been polled:\n%s", b.String())
+	}
+}
+
+func TestExporterScrapeDuringUpdate(t *testing.T) {
+	e := New()
+	pipelines := []PipelineHealth{{Pipeline: "alpha", Status: "Succeeded"}, {Pipeline: "beta", Status: "Failed"}}
+	e.Update(pipelines, time.Unix(1700000000, 0))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			e.Update(pipelines, time.Unix(1700000000+int64(i), 0))
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		var b strings.Builder
+		if err := e.Write(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{`cph_pipeline_status{pipeline="alpha",status="Succeeded"} 1`, `cph_pipeline_status{pipeline="beta",status="Failed"} 1`} {
+			if !strings.Contains(b.String(), want) {
+				t.Fatalf("scrape during an update is missing %q:\n%s", want, b.String())
+			}
+		}
+	}
+	<-done
+}
@@ -0,0 +1,315 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// Formats a graph can be drawn in
+var Formats = []string{"ascii", "mermaid", "dot"}
+
+// The structure of a pipeline: its stages in order, each stage's actions
+// grouped by run order, and the artifacts passed between actions
+type Graph struct {
+	Name   string
+	Stages []Stage
+	Flows  []Flow
+}
+
+type Stage struct {
+	ID     string
+	Name   string
+	Status string
+	// Actions grouped by run order. Actions in the same group run in parallel.
+	Groups [][]Action
+}
+
+type Action struct {
+	ID       string
+	Name     string
+	Provider string
+	RunOrder int32
+	Status   string
+	Inputs   []string
+	Outputs  []string
+}
+
+// An artifact output by one action and input to another
+type Flow struct {
+	From     string
+	To       string
+	Artifact string
+}
+
+// Builds the graph of a pipeline declaration. With a state, the latest status
+// of each stage and action is added.
+func Build(decl *types.PipelineDeclaration, state []types.StageState) *Graph {
+	stageStatus := make(map[string]string)
+	actionStatus := make(map[string]string)
+	for _, stage := range state {
+		if stage.LatestExecution != nil {
+			stageStatus[aws.ToString(stage.StageName)] = string(stage.LatestExecution.Status)
+		}
+		for _, action := range stage.ActionStates {
+			if action.LatestExecution != nil {
+				actionStatus[aws.ToString(stage.StageName)+"/"+aws.ToString(action.ActionName)] = string(action.LatestExecution.Status)
+			}
+		}
+	}
+
+	g := &Graph{Name: aws.ToString(decl.Name)}
+	producers := make(map[string]string)
+	type consumer struct {
+		id       string
+		artifact string
+	}
+	var consumers []consumer
+
+	for i, stageDecl := range decl.Stages {
+		stage := Stage{
+			ID:     fmt.Sprintf("s%v", i),
+			Name:   aws.ToString(stageDecl.Name),
+			Status: stageStatus[aws.ToString(stageDecl.Name)],
+		}
+
+		actions := make([]Action, len(stageDecl.Actions))
+		for j, actionDecl := range stageDecl.Actions {
+			action := Action{
+				ID:       fmt.Sprintf("s%va%v", i, j),
+				Name:     aws.ToString(actionDecl.Name),
+				RunOrder: aws.ToInt32(actionDecl.RunOrder),
+				Status:   actionStatus[stage.Name+"/"+aws.ToString(actionDecl.Name)],
+			}
+			if action.RunOrder == 0 {
+				action.RunOrder = 1
+			}
+			if actionDecl.ActionTypeId != nil {
+				action.Provider = aws.ToString(actionDecl.ActionTypeId.Provider)
+			}
+			for _, artifact := range actionDecl.InputArtifacts {
+				action.Inputs = append(action.Inputs, aws.ToString(artifact.Name))
+				consumers = append(consumers, consumer{action.ID, aws.ToString(artifact.Name)})
+			}
+			for _, artifact := range actionDecl.OutputArtifacts {
+				action.Outputs = append(action.Outputs, aws.ToString(artifact.Name))
+				producers[aws.ToString(artifact.Name)] = action.ID
+			}
+			actions[j] = action
+		}
+
+		sort.SliceStable(actions, func(a, b int) bool { return actions[a].RunOrder < actions[b].RunOrder })
+		for _, action := range actions {
+			last := len(stage.Groups) - 1
+			if last >= 0 && stage.Groups[last][0].RunOrder == action.RunOrder {
+				stage.Groups[last] = append(stage.Groups[last], action)
+			} else {
+				stage.Groups = append(stage.Groups, []Action{action})
+			}
+		}
+
+		g.Stages = append(g.Stages, stage)
+	}
+
+	// Artifact names are unique within a pipeline, so each input has one producer
+	for _, c := range consumers {
+		if from, ok := producers[c.artifact]; ok {
+			g.Flows = append(g.Flows, Flow{From: from, To: c.id, Artifact: c.artifact})
+		}
+	}
+
+	return g
+}
+
+// Checks that a format is one of Formats
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q, must be one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Draws the graph in the given format
+func Render(g *Graph, format string) (string, error) {
+	switch format {
+	case "ascii":
+		return ASCII(g), nil
+	case "mermaid":
+		return Mermaid(g), nil
+	case "dot":
+		return Dot(g), nil
+	default:
+		return "", ValidateFormat(format)
+	}
+}
+
+// Returns the colour used for a status, or "" for no status
+func statusColour(status string) string {
+	switch status {
+	case "Succeeded":
+		return "#b7e1cd"
+	case "InProgress":
+		return "#a4c2f4"
+	case "Failed", "Stopped", "Cancelled":
+		return "#f4a4a4"
+	case "Stopping":
+		return "#ffe599"
+	case "":
+		return ""
+	default:
+		return "#d9d9d9"
+	}
+}
+
+// Draws the graph as a Mermaid flowchart. Stages run left to right, solid
+// edges show the order actions run in and dotted edges show artifacts.
+func Mermaid(g *Graph) string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	statuses := make(map[string][]string)
+	for _, stage := range g.Stages {
+		fmt.Fprintf(&b, "  subgraph %s[\"%s\"]\n", stage.ID, mermaidText(stage.Name))
+		for _, group := range stage.Groups {
+			for _, action := range group {
+				fmt.Fprintf(&b, "    %s[\"%s<br/>%s\"]\n", action.ID, mermaidText(action.Name), mermaidText(action.Provider))
+				if action.Status != "" {
+					statuses[action.Status] = append(statuses[action.Status], action.ID)
+				}
+			}
+		}
+		for i := 1; i < len(stage.Groups); i++ {
+			for _, from := range stage.Groups[i-1] {
+				for _, to := range stage.Groups[i] {
+					fmt.Fprintf(&b, "    %s --> %s\n", from.ID, to.ID)
+				}
+			}
+		}
+		b.WriteString("  end\n")
+	}
+
+	for i := 1; i < len(g.Stages); i++ {
+		fmt.Fprintf(&b, "  %s --> %s\n", g.Stages[i-1].ID, g.Stages[i].ID)
+	}
+	for _, flow := range g.Flows {
+		fmt.Fprintf(&b, "  %s -. %s .-> %s\n", flow.From, mermaidText(flow.Artifact), flow.To)
+	}
+
+	var names []string
+	for status := range statuses {
+		names = append(names, status)
+	}
+	sort.Strings(names)
+	for _, status := range names {
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", strings.ToLower(status), statusColour(status))
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(statuses[status], ","), strings.ToLower(status))
+	}
+
+	return b.String()
+}
+
+func mermaidText(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// Draws the graph in Graphviz DOT. Stages are clusters, solid edges show the
+// order actions run in and dashed edges show artifacts.
+func Dot(g *Graph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotText(g.Name))
+	b.WriteString("  rankdir=LR;\n  compound=true;\n  node [shape=box];\n")
+
+	for i, stage := range g.Stages {
+		fmt.Fprintf(&b, "  subgraph cluster_%v {\n", i)
+		fmt.Fprintf(&b, "    label=%s;\n", dotText(stage.Name))
+		for _, group := range stage.Groups {
+			for _, action := range group {
+				attrs := "label=" + dotText(action.Name+"\n"+action.Provider)
+				if colour := statusColour(action.Status); colour != "" {
+					attrs += fmt.Sprintf(", style=filled, fillcolor=%q", colour)
+				}
+				fmt.Fprintf(&b, "    %s [%s];\n", action.ID, attrs)
+			}
+		}
+		for j := 1; j < len(stage.Groups); j++ {
+			for _, from := range stage.Groups[j-1] {
+				for _, to := range stage.Groups[j] {
+					fmt.Fprintf(&b, "    %s -> %s;\n", from.ID, to.ID)
+				}
+			}
+		}
+		b.WriteString("  }\n")
+	}
+
+	// Edges between clusters are drawn between their first actions
+	for i := 1; i < len(g.Stages); i++ {
+		from, to := firstAction(g.Stages[i-1]), firstAction(g.Stages[i])
+		if from == nil || to == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s [ltail=cluster_%v, lhead=cluster_%v];\n", from.ID, to.ID, i-1, i)
+	}
+	for _, flow := range g.Flows {
+		fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=%s];\n", flow.From, flow.To, dotText(flow.Artifact))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func firstAction(stage Stage) *Action {
+	if len(stage.Groups) == 0 {
+		return nil
+	}
+	return &stage.Groups[0][0]
+}
+
+func dotText(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// Draws the graph as text, one stage after another. Actions are numbered by
+// run order, so actions sharing a number run in parallel.
+func ASCII(g *Graph) string {
+	return ASCIIWith(g, func(status string) string { return status })
+}
+
+// Like ASCII, formatting each status with the given function, e.g. to colour it
+func ASCIIWith(g *Graph, formatStatus func(string) string) string {
+	var b strings.Builder
+	b.WriteString(g.Name + "\n")
+
+	for i, stage := range g.Stages {
+		if i > 0 {
+			b.WriteString("|\nv\n")
+		}
+		b.WriteString("+ " + stage.Name)
+		if stage.Status != "" {
+			b.WriteString(" [" + formatStatus(stage.Status) + "]")
+		}
+		b.WriteString("\n")
+
+		for _, group := range stage.Groups {
+			for _, action := range group {
+				fmt.Fprintf(&b, "|   %v. %s (%s)", action.RunOrder, action.Name, action.Provider)
+				if action.Status != "" {
+					b.WriteString(" [" + formatStatus(action.Status) + "]")
+				}
+				if len(action.Inputs) > 0 {
+					b.WriteString("  in: " + strings.Join(action.Inputs, ", "))
+				}
+				if len(action.Outputs) > 0 {
+					b.WriteString("  out: " + strings.Join(action.Outputs, ", "))
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+func TestBuild(t *testing.T) {
+	decl := &types.PipelineDeclaration{
+		Name: aws.String("alpha"),
+		Stages: []types.StageDeclaration{
+			{
+				Name: aws.String("Source"),
+				Actions: []types.ActionDeclaration{
+					{Name: aws.String("Source"), OutputArtifacts: []types.OutputArtifact{{Name: aws.String("src")}}},
+				},
+			},
+			{
+				Name: aws.String("Build"),
+				Actions: []types.ActionDeclaration{
+					{Name: aws.String("Package"), RunOrder: aws.Int32(2)},
+					{Name: aws.String("Unit"), RunOrder: aws.Int32(1), InputArtifacts: []types.InputArtifact{{Name: aws.String("src")}}},
+					{Name: aws.String("Lint"), RunOrder: aws.Int32(1)},
+				},
+			},
+		},
+	}
+	state := []types.StageState{{
+		StageName:    aws.String("Build"),
+		ActionStates: []types.ActionState{{ActionName: aws.String("Unit"), LatestExecution: &types.ActionExecution{Status: types.ActionExecutionStatusFailed}}},
+	}}
+
+	g := Build(decl, state)
+
+	groups := g.Stages[1].Groups
+	if len(groups) != 2 || len(groups[0]) != 2 || groups[0][0].Name != "Unit" || groups[0][1].Name != "Lint" || groups[1][0].Name != "Package" {
+		t.Errorf("Build stage groups = %+v, want [Unit Lint] then [Package]", groups)
+	}
+	if groups[0][0].Status != "Failed" {
+		t.Errorf("Unit status = %q, want Failed", groups[0][0].Status)
+	}
+	if len(g.Flows) != 1 || g.Flows[0] != (Flow{From: "s0a0", To: "s1a1", Artifact: "src"}) {
+		t.Errorf("flows = %+v, want src from Source to Unit", g.Flows)
+	}
+
+	if _, err := Render(g, "svg"); err == nil {
+		t.Error("Render() with an unknown format succeeded, want an error")
+	}
+}
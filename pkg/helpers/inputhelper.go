@@ -1,12 +1,55 @@
 package helpers
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+// Shared so that input buffered by one prompt isn't lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// Replaces where prompts read input from, e.g. to script input in tests
+func SetInput(r io.Reader) {
+	stdin = bufio.NewReader(r)
+}
+
+// Prints the prompt and reads a line of input. Returns ctx.Err() if the
+// context is cancelled (e.g. with Ctrl-C) before a line has been entered.
+func PromptInput(ctx context.Context, prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	type line struct {
+		text string
+		err  error
+	}
+	lines := make(chan line, 1)
+	go func() {
+		text, err := stdin.ReadString('\n')
+		if err == io.EOF && text != "" {
+			err = nil
+		}
+		lines <- line{strings.TrimRight(text, "\r\n"), err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	case l := <-lines:
+		if l.err == io.EOF {
+			return "", nil
+		}
+		return l.text, l.err
+	}
+}
+
 // Processes the user's input if it's a range
 func ProcessInputRange(userRange string, pipelineCount int) ([]int, error) {
 	// Ensure range is within number of pipelines retrieved
@@ -15,8 +58,8 @@ func ProcessInputRange(userRange string, pipelineCount int) ([]int, error) {
 	max, _ := strconv.Atoi(s[1])
 	if min >= max {
 		return nil, errors.New("invalid range provided")
-	} else if max-min > pipelineCount {
-		return nil, errors.New("range provided is larger than number of pipelines retrieved")
+	} else if min < 1 || max > pipelineCount {
+		return nil, errors.New("range provided is outside the pipelines retrieved")
 	}
 
 	return createNumbers(min, max), nil
@@ -24,22 +67,21 @@ func ProcessInputRange(userRange string, pipelineCount int) ([]int, error) {
 
 // Processes the user's input if it's a selection
 func ProcessInputSelection(userSelection string, pipelineCount int) ([]int, error) {
-	// Ensure lower and upper values are within number of pipelines retrieved
+	// Ensure every value is within the pipelines retrieved
 	userSelection = strings.ReplaceAll(userSelection, " ", "")
 	s := strings.Split(userSelection, ",")
-	sort.Strings(s)
-	min, _ := strconv.Atoi(s[0])
-	max, _ := strconv.Atoi(s[len(s)-1])
-	if min > pipelineCount || max > pipelineCount {
-		return nil, errors.New("specified range is out of bounds")
-	}
-
-	// Create int array
-	intArray := make([]int, len(s))
+	intArray := make([]int, 0, len(s))
 	for i := range s {
-		val, _ := strconv.Atoi(s[i])
-		intArray[i] = val
+		val, err := strconv.Atoi(s[i])
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s[i])
+		}
+		if val < 1 || val > pipelineCount {
+			return nil, errors.New("specified range is out of bounds")
+		}
+		intArray = append(intArray, val)
 	}
+	sort.Ints(intArray)
 
 	return intArray, nil
 }
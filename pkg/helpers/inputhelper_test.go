@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessInputRange(t *testing.T) {
+	got, err := ProcessInputRange("2-4", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ProcessInputRange("4-2", 5); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+	if _, err := ProcessInputRange("4-6", 5); err == nil {
+		t.Error("expected an error for a range out of bounds")
+	}
+	if _, err := ProcessInputRange("0-2", 5); err == nil {
+		t.Error("expected an error for a range starting at 0")
+	}
+}
+
+func TestProcessInputSelection(t *testing.T) {
+	got, err := ProcessInputSelection("1, 3,5", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ProcessInputSelection("1,6", 5); err == nil {
+		t.Error("expected an error for a selection out of bounds")
+	}
+	if _, err := ProcessInputSelection("0,2", 5); err == nil {
+		t.Error("expected an error for a selection including 0")
+	}
+
+	got, err = ProcessInputSelection("10,2", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPromptInput(t *testing.T) {
+	SetInput(strings.NewReader("yes\r\nno"))
+
+	for _, want := range []string{"yes", "no", ""} {
+		got, err := PromptInput(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported values for the --output flag
+var OutputFormats = []string{"table", "json", "csv"}
+
+// Returns an error if the format is not one of OutputFormats
+func ValidateOutputFormat(format string) error {
+	for _, f := range OutputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q, expected one of: %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// Writes rows to stdout in the given output format.
+// JSON output is an array of objects keyed by the camel cased header, e.g.
+// "Execution ID" becomes "executionId".
+func RenderOutput(format string, header []string, rows [][]string) error {
+	switch format {
+	case "json":
+		objects := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			object := make(map[string]string)
+			for i, value := range row {
+				object[jsonKey(header[i])] = value
+			}
+			objects = append(objects, object)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(objects)
+
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+
+	default:
+		table := SetupTable(header)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	}
+}
+
+// Converts a table header into a camel cased JSON key
+func jsonKey(header string) string {
+	words := strings.Fields(header)
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+		}
+	}
+	return strings.Join(words, "")
+}
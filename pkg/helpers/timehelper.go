@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+// Parses a point in time given on the command line. Accepts a duration
+// relative to now (e.g. 24h, 90m), a date (2006-01-02) or an RFC 3339 timestamp.
+// An empty value returns the zero time.
+func ParseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("could not parse time %q, expected a duration (e.g. 24h), a date (2006-01-02) or an RFC 3339 timestamp", value)
+}
+
+// Formats a timestamp for the given output format. Tables show the local time
+// in a readable form, while json and csv use RFC 3339 in UTC so the output can
+// be parsed.
+func FormatTime(t time.Time, format string) string {
+	if format == "table" {
+		return t.Local().Format("Jan 02 2006 15:04:05")
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,379 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// How serious a violation is
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+	// Turns a rule off
+	Off Severity = "off"
+)
+
+// Returns how serious a severity is, higher is more serious
+func (s Severity) Level() int {
+	switch s {
+	case Error:
+		return 3
+	case Warning:
+		return 2
+	case Info:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Checks that a severity is one of the known severities
+func ParseSeverity(s string) (Severity, error) {
+	switch severity := Severity(s); severity {
+	case Error, Warning, Info, Off:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("invalid severity %q, must be one of: error, warning, info, off", s)
+	}
+}
+
+// A pipeline breaking a rule. Stage and Action are empty when the violation is
+// not about a particular stage or action.
+type Violation struct {
+	Pipeline string
+	Rule     string
+	Severity Severity
+	Stage    string
+	Action   string
+	Message  string
+}
+
+// A check a pipeline's definition must pass
+type Rule struct {
+	Name        string
+	Severity    Severity
+	Description string
+	check       func(l *Linter, decl *types.PipelineDeclaration) []Violation
+}
+
+// A rule requiring an action configuration key to match a pattern, see
+// config.LintRule
+type CustomRule struct {
+	Name      string
+	Severity  Severity
+	Message   string
+	Pipelines string
+	Stage     string
+	Category  string
+	Provider  string
+	Config    string
+	Pattern   string
+}
+
+// Settings for a Linter
+type Options struct {
+	// Regular expression matching the names of production pipelines
+	ProdPattern string
+	// Severities overriding those of the built-in rules, by rule name
+	Severities map[string]Severity
+	Custom     []CustomRule
+}
+
+// Checks pipeline definitions against the built-in and custom rules
+type Linter struct {
+	rules       []Rule
+	prodPattern *regexp.Regexp
+}
+
+// Returns the built-in rules with their default severities
+func BuiltinRules() []Rule {
+	return []Rule{
+		{
+			Name:        "approval-before-deploy",
+			Severity:    Error,
+			Description: "Production pipelines have a manual approval before any deployment. Deployments are Deploy actions and actions in stages named Deploy.",
+			check:       checkApprovalBeforeDeploy,
+		},
+		{
+			Name:        "no-wildcard-branch",
+			Severity:    Error,
+			Description: "Source actions and triggers name their branches rather than using wildcards.",
+			check:       checkNoWildcardBranch,
+		},
+		{
+			Name:        "encrypted-artifact-store",
+			Severity:    Warning,
+			Description: "Artifact stores are encrypted with a customer managed KMS key.",
+			check:       checkEncryptedArtifactStore,
+		},
+	}
+}
+
+// Returns a Linter with the built-in rules, adjusted by the options
+func New(opts Options) (*Linter, error) {
+	prodPattern, err := regexp.Compile(opts.ProdPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid production pipeline pattern: %w", err)
+	}
+	l := &Linter{prodPattern: prodPattern}
+
+	known := make(map[string]bool)
+	for _, rule := range BuiltinRules() {
+		known[rule.Name] = true
+		if severity, ok := opts.Severities[rule.Name]; ok {
+			rule.Severity = severity
+		}
+		l.rules = append(l.rules, rule)
+	}
+	for name := range opts.Severities {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %s", name)
+		}
+	}
+
+	for _, custom := range opts.Custom {
+		rule, err := customRule(custom)
+		if err != nil {
+			return nil, err
+		}
+		if known[rule.Name] {
+			return nil, fmt.Errorf("lint rule %s is defined more than once", rule.Name)
+		}
+		known[rule.Name] = true
+		l.rules = append(l.rules, rule)
+	}
+
+	return l, nil
+}
+
+// Returns the rules the Linter checks, including turned off ones
+func (l *Linter) Rules() []Rule {
+	return l.rules
+}
+
+// Checks a pipeline's definition, returning violations in rule order
+func (l *Linter) Lint(decl *types.PipelineDeclaration) []Violation {
+	var violations []Violation
+	for _, rule := range l.rules {
+		if rule.Severity == Off {
+			continue
+		}
+		for _, v := range rule.check(l, decl) {
+			v.Pipeline = aws.ToString(decl.Name)
+			v.Rule = rule.Name
+			v.Severity = rule.Severity
+			violations = append(violations, v)
+		}
+	}
+
+	return violations
+}
+
+// Returns a stage's actions grouped by run order, in the order they run.
+// Actions without a run order run first.
+func runOrderGroups(stage types.StageDeclaration) [][]types.ActionDeclaration {
+	groups := make(map[int32][]types.ActionDeclaration)
+	var orders []int32
+	for _, action := range stage.Actions {
+		order := aws.ToInt32(action.RunOrder)
+		if order == 0 {
+			order = 1
+		}
+		if _, ok := groups[order]; !ok {
+			orders = append(orders, order)
+		}
+		groups[order] = append(groups[order], action)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i] < orders[j] })
+
+	var result [][]types.ActionDeclaration
+	for _, order := range orders {
+		result = append(result, groups[order])
+	}
+	return result
+}
+
+func category(action types.ActionDeclaration) types.ActionCategory {
+	if action.ActionTypeId == nil {
+		return ""
+	}
+	return action.ActionTypeId.Category
+}
+
+func checkApprovalBeforeDeploy(l *Linter, decl *types.PipelineDeclaration) []Violation {
+	if !l.prodPattern.MatchString(aws.ToString(decl.Name)) {
+		return nil
+	}
+
+	// Actions with the same run order run at the same time, so an approval
+	// only counts for actions with a later run order or in a later stage
+	approved := false
+	for _, stage := range decl.Stages {
+		deployStage := strings.Contains(strings.ToLower(aws.ToString(stage.Name)), "deploy")
+		for _, group := range runOrderGroups(stage) {
+			approvalInGroup := false
+			for _, action := range group {
+				if category(action) == types.ActionCategoryApproval {
+					approvalInGroup = true
+					continue
+				}
+				if !approved && (deployStage || category(action) == types.ActionCategoryDeploy) {
+					return []Violation{{
+						Stage:   aws.ToString(stage.Name),
+						Action:  aws.ToString(action.Name),
+						Message: "deploys without a manual approval before it",
+					}}
+				}
+			}
+			approved = approved || approvalInGroup
+		}
+	}
+
+	return nil
+}
+
+func checkNoWildcardBranch(l *Linter, decl *types.PipelineDeclaration) []Violation {
+	var violations []Violation
+	for _, stage := range decl.Stages {
+		for _, action := range stage.Actions {
+			if branch := action.Configuration["BranchName"]; strings.Contains(branch, "*") {
+				violations = append(violations, Violation{
+					Stage:   aws.ToString(stage.Name),
+					Action:  aws.ToString(action.Name),
+					Message: fmt.Sprintf("source branch %s is a wildcard", branch),
+				})
+			}
+		}
+	}
+
+	for _, trigger := range decl.Triggers {
+		git := trigger.GitConfiguration
+		if git == nil {
+			continue
+		}
+		var filters []*types.GitBranchFilterCriteria
+		for _, push := range git.Push {
+			filters = append(filters, push.Branches)
+		}
+		for _, pullRequest := range git.PullRequest {
+			filters = append(filters, pullRequest.Branches)
+		}
+		for _, filter := range filters {
+			if filter == nil {
+				continue
+			}
+			for _, branch := range filter.Includes {
+				if strings.Contains(branch, "*") {
+					violations = append(violations, Violation{
+						Action:  aws.ToString(git.SourceActionName),
+						Message: fmt.Sprintf("trigger branch %s is a wildcard", branch),
+					})
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+func checkEncryptedArtifactStore(l *Linter, decl *types.PipelineDeclaration) []Violation {
+	var violations []Violation
+	if store := decl.ArtifactStore; store != nil && store.EncryptionKey == nil {
+		violations = append(violations, Violation{
+			Message: fmt.Sprintf("artifact store %s has no encryption key", aws.ToString(store.Location)),
+		})
+	}
+
+	var regions []string
+	for region := range decl.ArtifactStores {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		if store := decl.ArtifactStores[region]; store.EncryptionKey == nil {
+			violations = append(violations, Violation{
+				Message: fmt.Sprintf("artifact store %s in %s has no encryption key", aws.ToString(store.Location), region),
+			})
+		}
+	}
+
+	return violations
+}
+
+// Turns a custom rule into a Rule, checking its settings
+func customRule(custom CustomRule) (Rule, error) {
+	if custom.Name == "" {
+		return Rule{}, fmt.Errorf("custom lint rules need a name")
+	}
+	if custom.Config == "" {
+		return Rule{}, fmt.Errorf("custom lint rule %s needs a config key to check", custom.Name)
+	}
+	severity := custom.Severity
+	if severity == "" {
+		severity = Error
+	}
+
+	var pipelines, stage, pattern *regexp.Regexp
+	for _, r := range []struct {
+		re    **regexp.Regexp
+		expr  string
+		field string
+	}{
+		{&pipelines, custom.Pipelines, "pipelines"},
+		{&stage, custom.Stage, "stage"},
+		{&pattern, custom.Pattern, "pattern"},
+	} {
+		re, err := regexp.Compile(r.expr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("custom lint rule %s has an invalid %s: %w", custom.Name, r.field, err)
+		}
+		*r.re = re
+	}
+
+	message := custom.Message
+	if message == "" {
+		message = fmt.Sprintf("configuration %s does not match %s", custom.Config, custom.Pattern)
+	}
+
+	return Rule{
+		Name:        custom.Name,
+		Severity:    severity,
+		Description: message,
+		check: func(l *Linter, decl *types.PipelineDeclaration) []Violation {
+			if !pipelines.MatchString(aws.ToString(decl.Name)) {
+				return nil
+			}
+
+			var violations []Violation
+			for _, s := range decl.Stages {
+				if !stage.MatchString(aws.ToString(s.Name)) {
+					continue
+				}
+				for _, action := range s.Actions {
+					if custom.Category != "" && string(category(action)) != custom.Category {
+						continue
+					}
+					if custom.Provider != "" && (action.ActionTypeId == nil || aws.ToString(action.ActionTypeId.Provider) != custom.Provider) {
+						continue
+					}
+					if !pattern.MatchString(action.Configuration[custom.Config]) {
+						violations = append(violations, Violation{
+							Stage:   aws.ToString(s.Name),
+							Action:  aws.ToString(action.Name),
+							Message: message,
+						})
+					}
+				}
+			}
+			return violations
+		},
+	}, nil
+}
@@ -0,0 +1,154 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+func action(name string, category types.ActionCategory, provider string, runOrder int32, configuration map[string]string) types.ActionDeclaration {
+	return types.ActionDeclaration{
+		Name:          aws.String(name),
+		ActionTypeId:  &types.ActionTypeId{Category: category, Provider: aws.String(provider)},
+		RunOrder:      aws.Int32(runOrder),
+		Configuration: configuration,
+	}
+}
+
+func stage(name string, actions ...types.ActionDeclaration) types.StageDeclaration {
+	return types.StageDeclaration{Name: aws.String(name), Actions: actions}
+}
+
+func pipeline(name string, stages ...types.StageDeclaration) *types.PipelineDeclaration {
+	return &types.PipelineDeclaration{
+		Name:   aws.String(name),
+		Stages: stages,
+		ArtifactStore: &types.ArtifactStore{
+			Location:      aws.String("bucket"),
+			EncryptionKey: &types.EncryptionKey{Id: aws.String("key")},
+		},
+	}
+}
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func newLinter(t *testing.T, opts Options) *Linter {
+	t.Helper()
+	if opts.ProdPattern == "" {
+		opts.ProdPattern = "prod"
+	}
+	l, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestApprovalBeforeDeploy(t *testing.T) {
+	source := stage("Source", action("Source", types.ActionCategorySource, "S3", 1, nil))
+	approval := action("Approve", types.ActionCategoryApproval, "Manual", 1, nil)
+	deploy := action("Deploy", types.ActionCategoryDeploy, "CloudFormation", 2, nil)
+
+	tests := []struct {
+		name string
+		decl *types.PipelineDeclaration
+		want int
+	}{
+		{"approval stage", pipeline("app-prod", source, stage("Approval", approval), stage("Release", deploy)), 0},
+		{"earlier run order", pipeline("app-prod", source, stage("Release", approval, deploy)), 0},
+		{"same run order", pipeline("app-prod", source, stage("Release", approval, action("Deploy", types.ActionCategoryDeploy, "CloudFormation", 1, nil))), 1},
+		{"no approval", pipeline("app-prod", source, stage("Release", deploy)), 1},
+		{"deploy stage", pipeline("app-prod", source, stage("Deploy", action("Build", types.ActionCategoryBuild, "CodeBuild", 1, nil))), 1},
+		{"not production", pipeline("app-staging", source, stage("Release", deploy)), 0},
+	}
+	l := newLinter(t, Options{})
+	for _, tt := range tests {
+		if got := l.Lint(tt.decl); len(got) != tt.want {
+			t.Errorf("%s: got violations %v, want %v", tt.name, rules(got), tt.want)
+		}
+	}
+}
+
+func TestNoWildcardBranch(t *testing.T) {
+	decl := pipeline("app", stage("Source", action("Source", types.ActionCategorySource, "CodeStarSourceConnection", 1, map[string]string{"BranchName": "release/*"})))
+	decl.Triggers = []types.PipelineTriggerDeclaration{{
+		GitConfiguration: &types.GitConfiguration{
+			SourceActionName: aws.String("Source"),
+			Push:             []types.GitPushFilter{{Branches: &types.GitBranchFilterCriteria{Includes: []string{"main", "feature-*"}}}},
+		},
+	}}
+
+	got := newLinter(t, Options{}).Lint(decl)
+	if len(got) != 2 || got[0].Rule != "no-wildcard-branch" || got[1].Message != "trigger branch feature-* is a wildcard" {
+		t.Errorf("got %+v, want the source action and the trigger to be reported", got)
+	}
+}
+
+func TestEncryptedArtifactStore(t *testing.T) {
+	decl := pipeline("app")
+	decl.ArtifactStore = nil
+	decl.ArtifactStores = map[string]types.ArtifactStore{
+		"us-east-1": {Location: aws.String("east"), EncryptionKey: &types.EncryptionKey{Id: aws.String("key")}},
+		"us-west-2": {Location: aws.String("west")},
+	}
+
+	got := newLinter(t, Options{}).Lint(decl)
+	if len(got) != 1 || got[0].Severity != Warning || got[0].Message != "artifact store west in us-west-2 has no encryption key" {
+		t.Errorf("got %+v, want the us-west-2 store to be reported", got)
+	}
+}
+
+func TestSeverities(t *testing.T) {
+	decl := pipeline("app-prod", stage("Deploy", action("Deploy", types.ActionCategoryDeploy, "ECS", 1, nil)))
+	decl.ArtifactStore.EncryptionKey = nil
+
+	got := newLinter(t, Options{Severities: map[string]Severity{"approval-before-deploy": Off, "encrypted-artifact-store": Error}}).Lint(decl)
+	if len(got) != 1 || got[0].Rule != "encrypted-artifact-store" || got[0].Severity != Error {
+		t.Errorf("got %+v, want only encrypted-artifact-store as an error", got)
+	}
+
+	if _, err := New(Options{Severities: map[string]Severity{"no-such-rule": Error}}); err == nil {
+		t.Error("New() with an unknown rule succeeded, want an error")
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	decl := pipeline("app",
+		stage("Build",
+			action("Build", types.ActionCategoryBuild, "CodeBuild", 1, map[string]string{"ProjectName": "team-build"}),
+			action("Test", types.ActionCategoryTest, "CodeBuild", 1, map[string]string{"ProjectName": "other-test"}),
+			action("Lambda", types.ActionCategoryInvoke, "Lambda", 1, map[string]string{"FunctionName": "other"}),
+		),
+	)
+
+	l := newLinter(t, Options{Custom: []CustomRule{{
+		Name:     "team-projects",
+		Severity: Warning,
+		Provider: "CodeBuild",
+		Config:   "ProjectName",
+		Pattern:  "^team-",
+	}}})
+	got := l.Lint(decl)
+	if len(got) != 1 || got[0].Rule != "team-projects" || got[0].Action != "Test" || got[0].Severity != Warning {
+		t.Errorf("got %+v, want the Test action to be reported", got)
+	}
+
+	invalid := []CustomRule{
+		{Config: "ProjectName"},
+		{Name: "no-config"},
+		{Name: "bad-pattern", Config: "ProjectName", Pattern: "("},
+		{Name: "no-wildcard-branch", Config: "BranchName"},
+	}
+	for _, rule := range invalid {
+		if _, err := New(Options{Custom: []CustomRule{rule}}); err == nil {
+			t.Errorf("New() with custom rule %+v succeeded, want an error", rule)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// A pipeline execution, reduced to what the metrics need
+type Execution struct {
+	Id     string
+	Status string
+	Start  time.Time
+	End    time.Time
+	// Stage of the failed action, for failed executions
+	FailedStage string
+}
+
+// Delivery metrics of one pipeline over a window of time
+type Summary struct {
+	Pipeline   string
+	Executions int
+	Succeeded  int
+	Failed     int
+	// Succeeded executions as a share of succeeded and failed ones, between 0
+	// and 1. NaN without any finished executions.
+	SuccessRate float64
+	// Durations of succeeded and failed executions
+	MeanDuration time.Duration
+	P90Duration  time.Duration
+	// Succeeded executions per day of the window
+	DeploymentsPerDay float64
+	// Mean time from the first failure in a run of failures to the next success
+	MeanTimeToRecover time.Duration
+	// Recoveries the mean time to recover is based on
+	Recoveries      int
+	FailuresByStage map[string]int
+}
+
+// Reports whether an execution that started at start is counted in the
+// metrics between since and until
+func InWindow(start time.Time, since time.Time, until time.Time) bool {
+	return !start.Before(since) && !start.After(until)
+}
+
+// Returns the metrics of a pipeline's executions that started between since
+// and until
+func Summarise(pipeline string, executions []Execution, since time.Time, until time.Time) Summary {
+	s := Summary{
+		Pipeline:        pipeline,
+		SuccessRate:     math.NaN(),
+		FailuresByStage: make(map[string]int),
+	}
+
+	var inWindow []Execution
+	for _, e := range executions {
+		if !InWindow(e.Start, since, until) {
+			continue
+		}
+		inWindow = append(inWindow, e)
+	}
+	sort.Slice(inWindow, func(i, j int) bool { return inWindow[i].Start.Before(inWindow[j].Start) })
+
+	var durations []time.Duration
+	var failedAt time.Time
+	var recovery time.Duration
+	for _, e := range inWindow {
+		s.Executions++
+		switch e.Status {
+		case "Succeeded":
+			s.Succeeded++
+			if !failedAt.IsZero() {
+				recovery += e.End.Sub(failedAt)
+				s.Recoveries++
+				failedAt = time.Time{}
+			}
+		case "Failed":
+			s.Failed++
+			if e.FailedStage != "" {
+				s.FailuresByStage[e.FailedStage]++
+			}
+			if failedAt.IsZero() {
+				failedAt = e.End
+			}
+		default:
+			continue
+		}
+		durations = append(durations, e.End.Sub(e.Start))
+	}
+
+	if finished := s.Succeeded + s.Failed; finished > 0 {
+		s.SuccessRate = float64(s.Succeeded) / float64(finished)
+	}
+	s.MeanDuration = mean(durations)
+	s.P90Duration = percentile(durations, 90)
+	if days := until.Sub(since).Hours() / 24; days > 0 {
+		s.DeploymentsPerDay = float64(s.Succeeded) / days
+	}
+	if s.Recoveries > 0 {
+		s.MeanTimeToRecover = recovery / time.Duration(s.Recoveries)
+	}
+
+	return s
+}
+
+func mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// Returns the nearest-rank percentile of the durations, 0 if there are none
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
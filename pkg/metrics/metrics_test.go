@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSummarise(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time { return base.Add(time.Duration(hours * float64(time.Hour))) }
+	executions := []Execution{
+		{Status: "Succeeded", Start: at(1), End: at(1.5)},
+		{Status: "Failed", Start: at(2), End: at(2.25), FailedStage: "Build"},
+		{Status: "Failed", Start: at(3), End: at(3.25), FailedStage: "Build"},
+		{Status: "Succeeded", Start: at(4), End: at(5)},
+		{Status: "Failed", Start: at(6), End: at(6.5), FailedStage: "Deploy"},
+		{Status: "Succeeded", Start: at(7), End: at(8)},
+		{Status: "Stopped", Start: at(9), End: at(9.1)},
+		{Status: "Succeeded", Start: at(-1), End: at(-0.5)},
+	}
+
+	s := Summarise("alpha", executions, base, base.Add(48*time.Hour))
+
+	if s.Executions != 7 || s.Succeeded != 3 || s.Failed != 3 {
+		t.Errorf("counts = %v/%v/%v, want 7/3/3", s.Executions, s.Succeeded, s.Failed)
+	}
+	if s.SuccessRate != 0.5 {
+		t.Errorf("SuccessRate = %v, want 0.5", s.SuccessRate)
+	}
+	if s.DeploymentsPerDay != 1.5 {
+		t.Errorf("DeploymentsPerDay = %v, want 1.5", s.DeploymentsPerDay)
+	}
+	// Recovered from 2.25 to 5 and from 6.5 to 8
+	if s.Recoveries != 2 || s.MeanTimeToRecover != 127*time.Minute+30*time.Second {
+		t.Errorf("MTTR = %v over %v recoveries, want 2h7m30s over 2", s.MeanTimeToRecover, s.Recoveries)
+	}
+	if s.FailuresByStage["Build"] != 2 || s.FailuresByStage["Deploy"] != 1 {
+		t.Errorf("FailuresByStage = %v, want Build: 2, Deploy: 1", s.FailuresByStage)
+	}
+	if s.P90Duration != time.Hour {
+		t.Errorf("P90Duration = %v, want 1h", s.P90Duration)
+	}
+}
+
+func TestSummariseEmpty(t *testing.T) {
+	s := Summarise("alpha", nil, time.Time{}, time.Time{})
+	if !math.IsNaN(s.SuccessRate) || s.MeanDuration != 0 || s.DeploymentsPerDay != 0 {
+		t.Errorf("Summarise() of no executions = %+v", s)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 10; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	if got := percentile(durations, 90); got != 9*time.Second {
+		t.Errorf("percentile(90) = %v, want 9s", got)
+	}
+	if got := percentile(durations, 50); got != 5*time.Second {
+		t.Errorf("percentile(50) = %v, want 5s", got)
+	}
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// One run of an action in a pipeline execution
+type ActionRun struct {
+	Stage  string
+	Action string
+	Start  time.Time
+	End    time.Time
+}
+
+// The action runs of one pipeline execution
+type ExecutionRuns struct {
+	Start   time.Time
+	Actions []ActionRun
+}
+
+// Duration statistics of a stage, or of an action when Action is set. Wait is
+// the time between the end of the previous stage (or the start of the
+// execution) and the start of the stage, e.g. time spent in a transition.
+type Timing struct {
+	Stage   string
+	Action  string
+	Runs    int
+	P50     time.Duration
+	P90     time.Duration
+	Max     time.Duration
+	WaitP50 time.Duration
+}
+
+// Returns the timings of each stage followed by the timings of its actions.
+// Executions are expected newest first, and stages are in the order they ran
+// in the newest execution that ran them. Actions are in the order they usually
+// start in.
+func Timings(executions []ExecutionRuns) []Timing {
+	stageDurations := make(map[string][]time.Duration)
+	stageWaits := make(map[string][]time.Duration)
+	actionDurations := make(map[string]map[string][]time.Duration)
+	actionOffsets := make(map[string]map[string][]time.Duration)
+	var stageOrder []string
+	seen := make(map[string]bool)
+
+	for _, execution := range executions {
+		stages := stageSpans(execution.Actions)
+		previousEnd := execution.Start
+		for _, span := range stages {
+			if !seen[span.stage] {
+				seen[span.stage] = true
+				stageOrder = append(stageOrder, span.stage)
+			}
+			stageDurations[span.stage] = append(stageDurations[span.stage], span.end.Sub(span.start))
+			if !previousEnd.IsZero() && span.start.After(previousEnd) {
+				stageWaits[span.stage] = append(stageWaits[span.stage], span.start.Sub(previousEnd))
+			} else {
+				stageWaits[span.stage] = append(stageWaits[span.stage], 0)
+			}
+			if span.end.After(previousEnd) {
+				previousEnd = span.end
+			}
+		}
+
+		for _, run := range execution.Actions {
+			if actionDurations[run.Stage] == nil {
+				actionDurations[run.Stage] = make(map[string][]time.Duration)
+				actionOffsets[run.Stage] = make(map[string][]time.Duration)
+			}
+			actionDurations[run.Stage][run.Action] = append(actionDurations[run.Stage][run.Action], run.End.Sub(run.Start))
+			actionOffsets[run.Stage][run.Action] = append(actionOffsets[run.Stage][run.Action], run.Start.Sub(execution.Start))
+		}
+	}
+
+	var timings []Timing
+	for _, stage := range stageOrder {
+		durations := stageDurations[stage]
+		timings = append(timings, Timing{
+			Stage:   stage,
+			Runs:    len(durations),
+			P50:     percentile(durations, 50),
+			P90:     percentile(durations, 90),
+			Max:     percentile(durations, 100),
+			WaitP50: percentile(stageWaits[stage], 50),
+		})
+
+		var actions []string
+		for action := range actionDurations[stage] {
+			actions = append(actions, action)
+		}
+		sort.Slice(actions, func(i, j int) bool {
+			oi, oj := percentile(actionOffsets[stage][actions[i]], 50), percentile(actionOffsets[stage][actions[j]], 50)
+			if oi != oj {
+				return oi < oj
+			}
+			return actions[i] < actions[j]
+		})
+		for _, action := range actions {
+			durations := actionDurations[stage][action]
+			timings = append(timings, Timing{
+				Stage:  stage,
+				Action: action,
+				Runs:   len(durations),
+				P50:    percentile(durations, 50),
+				P90:    percentile(durations, 90),
+				Max:    percentile(durations, 100),
+			})
+		}
+	}
+
+	return timings
+}
+
+type stageSpan struct {
+	stage      string
+	start, end time.Time
+}
+
+// Returns when each stage started and ended in an execution, in start order
+func stageSpans(runs []ActionRun) []stageSpan {
+	spans := make(map[string]*stageSpan)
+	var order []*stageSpan
+	for _, run := range runs {
+		span, ok := spans[run.Stage]
+		if !ok {
+			span = &stageSpan{stage: run.Stage, start: run.Start, end: run.End}
+			spans[run.Stage] = span
+			order = append(order, span)
+		}
+		if run.Start.Before(span.start) {
+			span.start = run.Start
+		}
+		if run.End.After(span.end) {
+			span.end = run.End
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].start.Before(order[j].start) })
+
+	result := make([]stageSpan, len(order))
+	for i, span := range order {
+		result[i] = *span
+	}
+	return result
+}
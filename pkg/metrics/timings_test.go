@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	executions := []ExecutionRuns{{
+		Start: at(0),
+		Actions: []ActionRun{
+			{Stage: "Test", Action: "Integration", Start: at(12), End: at(20)},
+			{Stage: "Test", Action: "Unit", Start: at(11), End: at(14)},
+			{Stage: "Build", Action: "Build", Start: at(1), End: at(10)},
+		},
+	}}
+
+	got := Timings(executions)
+	want := []Timing{
+		{Stage: "Build", Runs: 1, P50: 9 * time.Minute, P90: 9 * time.Minute, Max: 9 * time.Minute, WaitP50: time.Minute},
+		{Stage: "Build", Action: "Build", Runs: 1, P50: 9 * time.Minute, P90: 9 * time.Minute, Max: 9 * time.Minute},
+		{Stage: "Test", Runs: 1, P50: 9 * time.Minute, P90: 9 * time.Minute, Max: 9 * time.Minute, WaitP50: time.Minute},
+		{Stage: "Test", Action: "Unit", Runs: 1, P50: 3 * time.Minute, P90: 3 * time.Minute, Max: 3 * time.Minute},
+		{Stage: "Test", Action: "Integration", Runs: 1, P50: 8 * time.Minute, P90: 8 * time.Minute, Max: 8 * time.Minute},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Timings() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Timings()[%v] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
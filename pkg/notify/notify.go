@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Transitions that can be notified about. Any other status an execution can
+// finish with, e.g. Stopped or Superseded, can be used too.
+const (
+	Failed             = "Failed"
+	Succeeded          = "Succeeded"
+	WaitingForApproval = "WaitingForApproval"
+)
+
+// Transitions notified about when a sink does not list any
+var DefaultTransitions = []string{Failed, WaitingForApproval}
+
+// Something that happened to an execution being waited on
+type Event struct {
+	Pipeline    string `json:"pipeline"`
+	ExecutionId string `json:"executionId"`
+	// The status the execution finished with, or WaitingForApproval
+	Transition string `json:"transition"`
+	// The stage and action that failed or are waiting for approval, if known
+	Stage  string    `json:"stage,omitempty"`
+	Action string    `json:"action,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Returns a one line description of the event, e.g. "alpha is waiting for
+// approval of ManualApproval in Approval"
+func (e Event) Message() string {
+	switch {
+	case e.Transition == WaitingForApproval:
+		return fmt.Sprintf("%s is waiting for approval of %s in %s", e.Pipeline, e.Action, e.Stage)
+	case e.Stage != "":
+		return fmt.Sprintf("%s %s in %s", e.Pipeline, strings.ToLower(e.Transition), e.Stage)
+	default:
+		return fmt.Sprintf("%s %s", e.Pipeline, strings.ToLower(e.Transition))
+	}
+}
+
+// Somewhere events are sent
+type Sink interface {
+	Send(ctx context.Context, e Event) error
+}
+
+type target struct {
+	sink Sink
+	on   map[string]bool
+}
+
+// Sends events to every sink that is interested in their transition
+type Notifier struct {
+	targets []target
+}
+
+func New() *Notifier {
+	return &Notifier{}
+}
+
+// Adds a sink notified about the given transitions, or DefaultTransitions if
+// there are none
+func (n *Notifier) Add(sink Sink, transitions []string) {
+	if len(transitions) == 0 {
+		transitions = DefaultTransitions
+	}
+	on := make(map[string]bool)
+	for _, transition := range transitions {
+		on[strings.ToLower(transition)] = true
+	}
+	n.targets = append(n.targets, target{sink: sink, on: on})
+}
+
+// Reports whether any sink is notified about the transition
+func (n *Notifier) Wants(transition string) bool {
+	for _, t := range n.targets {
+		if t.on[strings.ToLower(transition)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends the event to the interested sinks. Every sink is tried, and the
+// errors of those that failed are returned together.
+func (n *Notifier) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, t := range n.targets {
+		if !t.on[strings.ToLower(e.Transition)] {
+			continue
+		}
+		if err := t.sink.Send(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
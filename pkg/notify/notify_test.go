@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	events []Event
+}
+
+func (s *recordingSink) Send(ctx context.Context, e Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestNotifierTransitions(t *testing.T) {
+	defaults := &recordingSink{}
+	succeeded := &recordingSink{}
+	n := New()
+	n.Add(defaults, nil)
+	n.Add(succeeded, []string{"succeeded"})
+
+	for _, transition := range []string{Failed, Succeeded, WaitingForApproval, "Stopped"} {
+		if err := n.Notify(context.Background(), Event{Pipeline: "alpha", Transition: transition}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(defaults.events) != 2 || defaults.events[0].Transition != Failed || defaults.events[1].Transition != WaitingForApproval {
+		t.Errorf("default sink got %+v, want Failed and WaitingForApproval", defaults.events)
+	}
+	if len(succeeded.events) != 1 || succeeded.events[0].Transition != Succeeded {
+		t.Errorf("succeeded sink got %+v, want Succeeded", succeeded.events)
+	}
+	if n.Wants("Stopped") {
+		t.Error("Wants(Stopped) = true, no sink is notified about it")
+	}
+}
+
+func TestEventMessage(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Pipeline: "alpha", Transition: WaitingForApproval, Stage: "Approval", Action: "ManualApproval"}, "alpha is waiting for approval of ManualApproval in Approval"},
+		{Event{Pipeline: "alpha", Transition: Failed, Stage: "Build"}, "alpha failed in Build"},
+		{Event{Pipeline: "alpha", Transition: Succeeded}, "alpha succeeded"},
+	}
+	for _, test := range tests {
+		if got := test.event.Message(); got != test.want {
+			t.Errorf("Message() = %q, want %q", got, test.want)
+		}
+	}
+}
+
+// Returns a server that records the body and headers of the request it receives
+func recordingServer(t *testing.T, status int) (*httptest.Server, *string, *http.Header) {
+	t.Helper()
+
+	var body string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		header = r.Header
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &body, &header
+}
+
+var failedEvent = Event{Pipeline: "alpha", ExecutionId: "exec-1", Transition: Failed, Stage: "Build", Time: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)}
+
+func TestWebhook(t *testing.T) {
+	server, body, header := recordingServer(t, http.StatusOK)
+
+	w, err := NewWebhook(server.URL, `{"text": {{json .Message}}, "id": {{json .ExecutionId}}}`, map[string]string{"Authorization": "Bearer secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(context.Background(), failedEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"text": "alpha failed in Build", "id": "exec-1"}`; *body != want {
+		t.Errorf("body = %s, want %s", *body, want)
+	}
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	server, body, _ := recordingServer(t, http.StatusOK)
+
+	w, err := NewWebhook(server.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(context.Background(), failedEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Event
+	if err := json.Unmarshal([]byte(*body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != failedEvent {
+		t.Errorf("posted %+v, want %+v", got, failedEvent)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	server, _, _ := recordingServer(t, http.StatusInternalServerError)
+
+	w, err := NewWebhook(server.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(context.Background(), failedEvent); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Send() = %v, want the status in the error", err)
+	}
+
+	w, err = NewWebhook(server.URL, `{"text": {{.Message}}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(context.Background(), failedEvent); err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("Send() = %v, want an invalid JSON error", err)
+	}
+
+	if _, err := NewWebhook(server.URL, `{{`, nil); err == nil {
+		t.Error("NewWebhook() with a broken template succeeded")
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	webhookTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { webhookTimeout = 10 * time.Second })
+
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	if err := NewSlack(server.URL).Send(context.Background(), failedEvent); err == nil {
+		t.Error("Send() to a hung webhook succeeded, want a timeout")
+	}
+}
+
+func TestSlack(t *testing.T) {
+	server, body, _ := recordingServer(t, http.StatusOK)
+
+	if err := NewSlack(server.URL).Send(context.Background(), failedEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"text":"alpha failed in Build (execution ` + "`exec-1`" + `)"}`; *body != want {
+		t.Errorf("body = %s, want %s", *body, want)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := Command{Command: `printf '%s|%s|%s' "$CPH_PIPELINE" "$CPH_TRANSITION" "$CPH_MESSAGE" > ` + out}
+	if err := c.Send(context.Background(), failedEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "alpha|Failed|alpha failed in Build"; string(got) != want {
+		t.Errorf("command got %q, want %q", got, want)
+	}
+
+	if err := (Command{Command: "echo broken >&2; exit 3"}).Send(context.Background(), failedEvent); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Send() = %v, want the command's output in the error", err)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	commandTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { commandTimeout = 10 * time.Second })
+
+	start := time.Now()
+	if err := (Command{Command: "sleep 10"}).Send(context.Background(), failedEvent); err == nil {
+		t.Error("Send() with a hung command succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s, want it killed after the timeout", elapsed)
+	}
+}
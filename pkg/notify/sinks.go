@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// How long a webhook has to answer, so a hung one can't stall the wait it
+// was sent from
+var webhookTimeout = 10 * time.Second
+
+// How long a notification command can run before it's killed, for the same reason
+var commandTimeout = 10 * time.Second
+
+// Posts events as JSON to a URL. The body is the event itself, or the
+// result of a Go template given the event, e.g.
+//
+//	{"text": {{json .Message}}, "pipeline": {{json .Pipeline}}}
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+	body    *template.Template
+}
+
+// Returns a webhook posting to url. An empty body template posts the event as JSON.
+func NewWebhook(url string, body string, headers map[string]string) (*Webhook, error) {
+	w := &Webhook{URL: url, Headers: headers, Client: &http.Client{Timeout: webhookTimeout}}
+	if body != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse webhook template: %w", err)
+		}
+		w.body = tmpl
+	}
+
+	return w, nil
+}
+
+func (w *Webhook) Send(ctx context.Context, e Event) error {
+	var body []byte
+	if w.body == nil {
+		var err error
+		body, err = json.Marshal(e)
+		if err != nil {
+			return err
+		}
+	} else {
+		var buf bytes.Buffer
+		// Message is a method, so give templates a copy that has it as a field
+		data := struct {
+			Event
+			Message string
+		}{e, e.Message()}
+		if err := w.body.Execute(&buf, data); err != nil {
+			return fmt.Errorf("could not render webhook template: %w", err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			return fmt.Errorf("webhook template did not render valid JSON: %s", buf.String())
+		}
+		body = buf.Bytes()
+	}
+
+	return postJSON(ctx, w.Client, w.URL, body, w.Headers)
+}
+
+// Posts events to a Slack incoming webhook, or anything that accepts the same payload
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewSlack(url string) *Slack {
+	return &Slack{URL: url, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (s *Slack) Send(ctx context.Context, e Event) error {
+	text := e.Message()
+	if e.ExecutionId != "" {
+		text += fmt.Sprintf(" (execution `%s`)", e.ExecutionId)
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, s.Client, s.URL, body, nil)
+}
+
+// Shows events as desktop notifications with notify-send
+type Desktop struct{}
+
+func (Desktop) Send(ctx context.Context, e Event) error {
+	urgency := "normal"
+	if e.Transition == Failed {
+		urgency = "critical"
+	}
+	out, err := exec.CommandContext(ctx, "notify-send", "--urgency", urgency, "cph: "+e.Pipeline, e.Message()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notify-send failed: %w %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Runs a shell command for each event. The event is passed in environment
+// variables rather than in the command line, so it never needs quoting:
+// CPH_PIPELINE, CPH_EXECUTION_ID, CPH_TRANSITION, CPH_STAGE, CPH_ACTION and CPH_MESSAGE.
+type Command struct {
+	Command string
+}
+
+func (c Command) Send(ctx context.Context, e Event) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	// Killing sh leaves anything it started holding the output open, so stop waiting for it
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"CPH_PIPELINE="+e.Pipeline,
+		"CPH_EXECUTION_ID="+e.ExecutionId,
+		"CPH_TRANSITION="+e.Transition,
+		"CPH_STAGE="+e.Stage,
+		"CPH_ACTION="+e.Action,
+		"CPH_MESSAGE="+e.Message(),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notification command failed: %w %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+
+	return nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
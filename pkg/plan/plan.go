@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Plan declares a set of pipelines and the pipelines each one depends on.
+// Example plan file:
+//
+//	pipelines:
+//	  - name: infra
+//	  - name: shared-libs
+//	    dependsOn: [infra]
+//	  - name: service-a
+//	    dependsOn: [shared-libs]
+type Plan struct {
+	Pipelines []Pipeline `yaml:"pipelines"`
+}
+
+type Pipeline struct {
+	Name      string   `yaml:"name"`
+	DependsOn []string `yaml:"dependsOn"`
+}
+
+// Reads and parses a plan file
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plan
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("could not parse plan file %s: %w", path, err)
+	}
+	if len(p.Pipelines) == 0 {
+		return nil, errors.New("plan file does not declare any pipelines")
+	}
+
+	return &p, nil
+}
+
+// Groups the plan's pipelines into waves. Every pipeline in a wave only depends
+// on pipelines in earlier waves, so a wave can be started once the previous
+// one has finished. Pipelines keep the order they were declared in within a wave.
+func (p *Plan) Waves() ([][]string, error) {
+	// Validate names and dependencies
+	declared := make(map[string]bool)
+	for _, pipeline := range p.Pipelines {
+		if pipeline.Name == "" {
+			return nil, errors.New("plan contains a pipeline without a name")
+		}
+		if declared[pipeline.Name] {
+			return nil, fmt.Errorf("pipeline %s is declared more than once", pipeline.Name)
+		}
+		declared[pipeline.Name] = true
+	}
+	for _, pipeline := range p.Pipelines {
+		for _, dep := range pipeline.DependsOn {
+			if !declared[dep] {
+				return nil, fmt.Errorf("pipeline %s depends on %s, which is not declared in the plan", pipeline.Name, dep)
+			}
+		}
+	}
+
+	// Repeatedly take every pipeline whose dependencies have all been scheduled
+	var waves [][]string
+	scheduled := make(map[string]bool)
+	for len(scheduled) < len(p.Pipelines) {
+		var wave []string
+		for _, pipeline := range p.Pipelines {
+			if scheduled[pipeline.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range pipeline.DependsOn {
+				if !scheduled[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, pipeline.Name)
+			}
+		}
+
+		if len(wave) == 0 {
+			var remaining []string
+			for _, pipeline := range p.Pipelines {
+				if !scheduled[pipeline.Name] {
+					remaining = append(remaining, pipeline.Name)
+				}
+			}
+			return nil, fmt.Errorf("plan contains a dependency cycle between: %s", strings.Join(remaining, ", "))
+		}
+
+		for _, name := range wave {
+			scheduled[name] = true
+		}
+		waves = append(waves, wave)
+	}
+
+	return waves, nil
+}
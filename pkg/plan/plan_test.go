@@ -0,0 +1,89 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWaves(t *testing.T) {
+	p := &Plan{Pipelines: []Pipeline{
+		{Name: "service-a", DependsOn: []string{"shared-libs"}},
+		{Name: "infra"},
+		{Name: "shared-libs", DependsOn: []string{"infra"}},
+		{Name: "service-b", DependsOn: []string{"shared-libs", "infra"}},
+		{Name: "docs"},
+	}}
+
+	waves, err := p.Waves()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"infra", "docs"},
+		{"shared-libs"},
+		{"service-a", "service-b"},
+	}
+	if !reflect.DeepEqual(waves, want) {
+		t.Errorf("got %v, want %v", waves, want)
+	}
+}
+
+func TestWavesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		pipelines []Pipeline
+		wantErr   string
+	}{
+		{
+			name:      "unknown dependency",
+			pipelines: []Pipeline{{Name: "a", DependsOn: []string{"missing"}}},
+			wantErr:   "not declared",
+		},
+		{
+			name:      "duplicate",
+			pipelines: []Pipeline{{Name: "a"}, {Name: "a"}},
+			wantErr:   "more than once",
+		},
+		{
+			name:      "cycle",
+			pipelines: []Pipeline{{Name: "root"}, {Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			wantErr:   "cycle between: a, b",
+		},
+		{
+			name:      "missing name",
+			pipelines: []Pipeline{{DependsOn: []string{"a"}}},
+			wantErr:   "without a name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Plan{Pipelines: tt.pipelines}).Waves()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	err := os.WriteFile(path, []byte("pipelines:\n  - name: infra\n  - name: app\n    dependsOn: [infra]\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Pipeline{{Name: "infra"}, {Name: "app", DependsOn: []string{"infra"}}}
+	if !reflect.DeepEqual(p.Pipelines, want) {
+		t.Errorf("got %v, want %v", p.Pipelines, want)
+	}
+}
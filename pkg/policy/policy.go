@@ -0,0 +1,388 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Commands a policy is evaluated for
+const (
+	Run     = "run"
+	Approve = "approve"
+)
+
+// Rules that can deny a command
+const (
+	SelfApproval  = "self-approval"
+	UnknownAuthor = "unknown-author"
+	RequireReason = "require-reason"
+	BlockedWindow = "blocked-window"
+	MaxBatchSize  = "max-batch-size"
+)
+
+// Guardrails checked before pipelines are run or approved. Example policy file:
+//
+//	denySelfApproval: true
+//	denyUnknownAuthor: true
+//	requireReason: ["-prod$"]
+//	maxBatchSize: 5
+//	blockedWindows:
+//	  - name: weekend freeze
+//	    pipelines: "-prod$"
+//	    days: [Sat, Sun]
+//	  - name: evenings
+//	    pipelines: "-prod$"
+//	    commands: [run]
+//	    days: [Mon, Tue, Wed, Thu, Fri]
+//	    start: "18:00"
+//	    end: "08:00"
+//	    timezone: Europe/London
+type Policy struct {
+	// Deny approving an execution the caller started or a change they wrote
+	DenySelfApproval bool `yaml:"denySelfApproval"`
+	// With DenySelfApproval, deny approving a change whose author can't be
+	// found, rather than warning about it
+	DenyUnknownAuthor bool `yaml:"denyUnknownAuthor"`
+	// Regular expressions matching the names of pipelines that need a reason
+	RequireReason []string `yaml:"requireReason"`
+	// Times when pipelines can't be run or approved
+	BlockedWindows []Window `yaml:"blockedWindows"`
+	// Largest number of pipelines one command may run or approve, 0 for no limit
+	MaxBatchSize int `yaml:"maxBatchSize"`
+
+	requireReason []*regexp.Regexp
+}
+
+// A time when pipelines can't be run or approved. A window whose end is before
+// its start runs past midnight into the next day.
+type Window struct {
+	Name string `yaml:"name"`
+	// Regular expression matching the names of the pipelines, all if empty
+	Pipelines string `yaml:"pipelines"`
+	// run, approve or both if empty
+	Commands []string `yaml:"commands"`
+	// Days the window starts on, e.g. Mon or Monday, every day if empty
+	Days []string `yaml:"days"`
+	// Times of day as 15:04, the whole day if empty. End can be 24:00.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// IANA time zone of the days and times, local time if empty
+	Timezone string `yaml:"timezone"`
+
+	pipelines *regexp.Regexp
+	days      map[time.Weekday]bool
+	start     int
+	end       int
+	location  *time.Location
+}
+
+// A pipeline a command would run or approve
+type Target struct {
+	Pipeline string
+	// ARN of whoever started the execution being approved, if known
+	StartedBy string
+	// Names and email addresses of the authors of the change being approved,
+	// empty if they can't be found
+	Authors []string
+}
+
+// A command to check against the policy
+type Request struct {
+	// Run or Approve
+	Command string
+	Targets []Target
+	// ARN of the caller
+	Caller string
+	Reason string
+	Time   time.Time
+}
+
+// Why a command was denied. Pipeline is empty for denials of the whole command.
+type Denial struct {
+	Rule     string
+	Pipeline string
+	Message  string
+}
+
+// Reads and parses a policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse policy file %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// Parses and validates a policy
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range p.RequireReason {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("requireReason: %w", err)
+		}
+		p.requireReason = append(p.requireReason, re)
+	}
+	if p.MaxBatchSize < 0 {
+		return nil, errors.New("maxBatchSize must not be negative")
+	}
+	for i := range p.BlockedWindows {
+		if err := p.BlockedWindows[i].compile(); err != nil {
+			return nil, fmt.Errorf("blocked window %s: %w", p.BlockedWindows[i].label(i), err)
+		}
+	}
+
+	return &p, nil
+}
+
+func (w *Window) compile() error {
+	var err error
+	if w.Pipelines != "" {
+		w.pipelines, err = regexp.Compile(w.Pipelines)
+		if err != nil {
+			return err
+		}
+	}
+	for _, command := range w.Commands {
+		if command != Run && command != Approve {
+			return fmt.Errorf("unknown command %q, expected run or approve", command)
+		}
+	}
+
+	w.days = make(map[time.Weekday]bool)
+	for _, day := range w.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return err
+		}
+		w.days[weekday] = true
+	}
+
+	w.start, w.end = 0, 24*60
+	if w.Start != "" {
+		if w.start, err = parseTimeOfDay(w.Start); err != nil {
+			return err
+		}
+	}
+	if w.End != "" {
+		if w.end, err = parseTimeOfDay(w.End); err != nil {
+			return err
+		}
+	}
+	if w.start == w.end {
+		return errors.New("start and end are the same time")
+	}
+
+	w.location = time.Local
+	if w.Timezone != "" {
+		if w.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the window's name, or its position if it has none
+func (w *Window) label(i int) string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return fmt.Sprintf("%v", i+1)
+}
+
+// Reports whether t is within the window
+func (w *Window) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	onDay := func(day time.Weekday) bool { return len(w.days) == 0 || w.days[day] }
+
+	if w.start < w.end {
+		return onDay(t.Weekday()) && minute >= w.start && minute < w.end
+	}
+	// Runs past midnight, so it is either the evening of a day it starts on
+	// or the morning after
+	return (onDay(t.Weekday()) && minute >= w.start) || (onDay((t.Weekday()+6)%7) && minute < w.end)
+}
+
+func (w *Window) appliesTo(command string, pipeline string) bool {
+	if len(w.Commands) > 0 && !contains(w.Commands, command) {
+		return false
+	}
+	return w.pipelines == nil || w.pipelines.MatchString(pipeline)
+}
+
+// Describes when the window is, e.g. "Sat, Sun 00:00-24:00 Europe/London"
+func (w *Window) describe() string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ", ")
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, formatTimeOfDay(w.start), formatTimeOfDay(w.end), w.location)
+}
+
+// Returns the reasons the policy denies the request, or nil if it is allowed
+func (p *Policy) Evaluate(req Request) []Denial {
+	var denials []Denial
+
+	if p.MaxBatchSize > 0 && len(req.Targets) > p.MaxBatchSize {
+		denials = append(denials, Denial{
+			Rule:    MaxBatchSize,
+			Message: fmt.Sprintf("cannot %s %v pipelines at once, the limit is %v", req.Command, len(req.Targets), p.MaxBatchSize),
+		})
+	}
+
+	for _, target := range req.Targets {
+		if req.Command == Approve && p.DenySelfApproval {
+			if target.StartedBy != "" && target.StartedBy == req.Caller {
+				denials = append(denials, Denial{
+					Rule:     SelfApproval,
+					Pipeline: target.Pipeline,
+					Message:  fmt.Sprintf("you started the execution of %s waiting for approval, someone else has to approve it", target.Pipeline),
+				})
+			} else if isAuthor(req.Caller, target.Authors) {
+				denials = append(denials, Denial{
+					Rule:     SelfApproval,
+					Pipeline: target.Pipeline,
+					Message:  fmt.Sprintf("you wrote the change %s is waiting to approve, someone else has to approve it", target.Pipeline),
+				})
+			}
+			if len(target.Authors) == 0 && p.DenyUnknownAuthor {
+				denials = append(denials, Denial{
+					Rule:     UnknownAuthor,
+					Pipeline: target.Pipeline,
+					Message:  unknownAuthorMessage(target.Pipeline),
+				})
+			}
+		}
+
+		if strings.TrimSpace(req.Reason) == "" {
+			for _, re := range p.requireReason {
+				if re.MatchString(target.Pipeline) {
+					denials = append(denials, Denial{
+						Rule:     RequireReason,
+						Pipeline: target.Pipeline,
+						Message:  fmt.Sprintf("%s needs a --reason to %s", target.Pipeline, req.Command),
+					})
+					break
+				}
+			}
+		}
+
+		for i := range p.BlockedWindows {
+			w := &p.BlockedWindows[i]
+			if w.appliesTo(req.Command, target.Pipeline) && w.contains(req.Time) {
+				denials = append(denials, Denial{
+					Rule:     BlockedWindow,
+					Pipeline: target.Pipeline,
+					Message:  fmt.Sprintf("%s cannot be %s during %s (%s)", target.Pipeline, pastTense(req.Command), w.label(i), w.describe()),
+				})
+			}
+		}
+	}
+
+	return denials
+}
+
+// Returns what the request is allowed despite, e.g. approvals that can't be
+// checked for self-approval as their author can't be found
+func (p *Policy) Warnings(req Request) []string {
+	var warnings []string
+	if req.Command != Approve || !p.DenySelfApproval || p.DenyUnknownAuthor {
+		return warnings
+	}
+
+	for _, target := range req.Targets {
+		if len(target.Authors) == 0 {
+			warnings = append(warnings, unknownAuthorMessage(target.Pipeline))
+		}
+	}
+
+	return warnings
+}
+
+func unknownAuthorMessage(pipeline string) string {
+	return fmt.Sprintf("the author of the change %s is waiting to approve can't be found, so it can't be checked for self-approval", pipeline)
+}
+
+// Reports whether the caller is one of the authors. Authors are git names and
+// email addresses rather than AWS identities, so they are compared with the
+// caller's user or role session name, ignoring case and any email domain.
+func isAuthor(caller string, authors []string) bool {
+	if caller == "" {
+		return false
+	}
+	name := caller[strings.LastIndex(caller, "/")+1:]
+	for _, author := range authors {
+		if author != "" && strings.EqualFold(localPart(author), localPart(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the part of an email address before the @, or the whole value if
+// it isn't one
+func localPart(value string) string {
+	if i := strings.Index(value, "@"); i > 0 {
+		return value[:i]
+	}
+	return value
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()) || strings.EqualFold(day, d.String()[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q, expected e.g. Mon or Monday", day)
+}
+
+// Returns the minutes since midnight of a time of day, e.g. 09:30
+func parseTimeOfDay(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse time of day %q, expected e.g. 09:30", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatTimeOfDay(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func pastTense(command string) string {
+	if command == Run {
+		return "run"
+	}
+	return command + "d"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
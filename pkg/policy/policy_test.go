@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, data string) *Policy {
+	t.Helper()
+
+	p, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Returns the rule and pipeline of each denial
+func ruleNames(denials []Denial) []string {
+	var names []string
+	for _, d := range denials {
+		names = append(names, d.Rule+" "+d.Pipeline)
+	}
+	return names
+}
+
+// Saturday 14:00 UTC
+var saturday = time.Date(2023, 11, 18, 14, 0, 0, 0, time.UTC)
+
+func TestEvaluate(t *testing.T) {
+	p := mustParse(t, `
+denySelfApproval: true
+requireReason: ["-prod$"]
+maxBatchSize: 2
+blockedWindows:
+  - name: weekend freeze
+    pipelines: "-prod$"
+    days: [Sat, Sun]
+    timezone: UTC
+`)
+	caller := "arn:aws:iam::123456789012:user/alice"
+
+	tests := []struct {
+		name string
+		req  Request
+		want []string
+	}{
+		{
+			name: "allowed",
+			req:  Request{Command: Run, Targets: []Target{{Pipeline: "app-dev"}}, Time: saturday},
+		},
+		{
+			name: "production at the weekend without a reason",
+			req:  Request{Command: Run, Targets: []Target{{Pipeline: "app-prod"}}, Time: saturday},
+			want: []string{"require-reason app-prod", "blocked-window app-prod"},
+		},
+		{
+			name: "production on a weekday with a reason",
+			req:  Request{Command: Run, Targets: []Target{{Pipeline: "app-prod"}}, Reason: "hotfix", Time: saturday.AddDate(0, 0, 2)},
+		},
+		{
+			name: "self approval",
+			req: Request{Command: Approve, Caller: caller, Time: saturday,
+				Targets: []Target{{Pipeline: "app-dev", StartedBy: caller}, {Pipeline: "web-dev", StartedBy: "arn:aws:iam::123456789012:user/bob"}}},
+			want: []string{"self-approval app-dev"},
+		},
+		{
+			name: "approving a change you wrote",
+			req: Request{Command: Approve, Caller: "arn:aws:sts::123456789012:assumed-role/Developer/Alice@example.com", Time: saturday,
+				Targets: []Target{{Pipeline: "app-dev", Authors: []string{"Alice Smith", "alice@example.com"}}, {Pipeline: "web-dev", Authors: []string{"bob"}}}},
+			want: []string{"self-approval app-dev"},
+		},
+		{
+			name: "running an execution you started is not self approval",
+			req:  Request{Command: Run, Caller: caller, Targets: []Target{{Pipeline: "app-dev", StartedBy: caller}}, Time: saturday},
+		},
+		{
+			name: "batch too large",
+			req:  Request{Command: Run, Targets: []Target{{Pipeline: "a"}, {Pipeline: "b"}, {Pipeline: "c"}}, Time: saturday},
+			want: []string{"max-batch-size "},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ruleNames(p.Evaluate(test.req)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got denials %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnknownAuthor(t *testing.T) {
+	req := Request{Command: Approve, Caller: "arn:aws:iam::123456789012:user/alice", Time: saturday,
+		Targets: []Target{{Pipeline: "app-dev"}, {Pipeline: "web-dev", Authors: []string{"bob"}}}}
+
+	p := mustParse(t, "denySelfApproval: true\n")
+	if got := ruleNames(p.Evaluate(req)); got != nil {
+		t.Errorf("got denials %v, want none", got)
+	}
+	if got := p.Warnings(req); len(got) != 1 || !strings.Contains(got[0], "app-dev") {
+		t.Errorf("got warnings %v, want one about app-dev", got)
+	}
+
+	p = mustParse(t, "denySelfApproval: true\ndenyUnknownAuthor: true\n")
+	if got, want := ruleNames(p.Evaluate(req)), []string{"unknown-author app-dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got denials %v, want %v", got, want)
+	}
+	if got := p.Warnings(req); got != nil {
+		t.Errorf("got warnings %v, want none when unknown authors are denied", got)
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	p := mustParse(t, `
+blockedWindows:
+  - name: evenings
+    commands: [run]
+    days: [Friday]
+    start: "18:00"
+    end: "08:00"
+    timezone: America/New_York
+`)
+	friday := time.Date(2023, 11, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{friday.Add(22*time.Hour + 59*time.Minute), false}, // 17:59 in New York
+		{friday.Add(23 * time.Hour), true},                 // 18:00
+		{friday.Add(36 * time.Hour), true},                 // 07:00 on Saturday
+		{friday.Add(37 * time.Hour), false},                // 08:00 on Saturday
+		{friday.Add(-time.Hour), false},                    // 18:00 on Thursday
+	}
+	for _, test := range tests {
+		denials := p.Evaluate(Request{Command: Run, Targets: []Target{{Pipeline: "app"}}, Time: test.at})
+		if got := len(denials) > 0; got != test.want {
+			t.Errorf("at %v: blocked = %v, want %v", test.at, got, test.want)
+		}
+	}
+
+	denials := p.Evaluate(Request{Command: Approve, Targets: []Target{{Pipeline: "app"}}, Time: friday.Add(23 * time.Hour)})
+	if len(denials) > 0 {
+		t.Errorf("window limited to run denied an approval: %v", denials)
+	}
+
+	denials = p.Evaluate(Request{Command: Run, Targets: []Target{{Pipeline: "app"}}, Time: friday.Add(23 * time.Hour)})
+	want := "app cannot be run during evenings (Friday 18:00-08:00 America/New_York)"
+	if len(denials) != 1 || denials[0].Message != want {
+		t.Errorf("got %v, want the message %q", denials, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr string
+	}{
+		{`requireReason: ["("]`, "requireReason"},
+		{`maxBatchSize: -1`, "maxBatchSize"},
+		{"blockedWindows:\n  - days: [Funday]", "unknown day"},
+		{"blockedWindows:\n  - name: lunch\n    start: \"12\"", "blocked window lunch: could not parse time of day"},
+		{"blockedWindows:\n  - commands: [deploy]", "unknown command"},
+		{"blockedWindows:\n  - start: \"09:00\"\n    end: \"09:00\"", "same time"},
+		{"blockedWindows:\n  - timezone: Mars/Olympus", "Mars/Olympus"},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.policy))
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", test.policy, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A fixture file holds the AWS HTTP interactions of one test, in the order
+// they happened. Example fixture file:
+//
+//	{
+//	  "interactions": [
+//	    {
+//	      "service": "codepipeline",
+//	      "operation": "ListPipelines",
+//	      "request": "{\"maxResults\":1000}",
+//	      "status": 200,
+//	      "response": "{\"pipelines\":[{\"name\":\"my-pipeline\"}]}"
+//	    }
+//	  ]
+//	}
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	Request   string `json:"request"`
+	Status    int    `json:"status"`
+	Response  string `json:"response"`
+}
+
+// Reads a fixture file
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("could not parse fixture file %s: %w", path, err)
+	}
+
+	return &f, nil
+}
+
+// Writes a fixture file
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// An HTTP client that sends requests to AWS and records every interaction
+type Recorder struct {
+	Client  *http.Client
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{Client: http.DefaultClient}
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	service, operation := describe(req, reqBody)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Interactions = append(r.fixture.Interactions, Interaction{
+		Service:   service,
+		Operation: operation,
+		Request:   reqBody,
+		Status:    resp.StatusCode,
+		Response:  respBody,
+	})
+
+	return resp, nil
+}
+
+// Returns the interactions recorded so far
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := Fixture{Interactions: append([]Interaction(nil), r.fixture.Interactions...)}
+	return &f
+}
+
+// An HTTP client that answers requests from a fixture instead of calling AWS.
+// Requests are matched on service, operation and body. Matching interactions
+// are replayed in order, and the last one is repeated once they run out, so
+// repeated identical calls (e.g. polling) don't each need recording. Calls
+// that change something are never repeated, so each one must be recorded.
+type Replayer struct {
+	mu      sync.Mutex
+	fixture *Fixture
+	used    map[int]bool
+	last    map[string]Interaction
+}
+
+func NewReplayer(f *Fixture) *Replayer {
+	return &Replayer{fixture: f, used: make(map[int]bool), last: make(map[string]Interaction)}
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	service, operation := describe(req, reqBody)
+	key := service + " " + operation + " " + normalise(reqBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var interaction Interaction
+	var found bool
+	if readOnly(operation) {
+		interaction, found = r.last[key]
+	}
+	for i, candidate := range r.fixture.Interactions {
+		if r.used[i] || candidate.Service != service || candidate.Operation != operation || normalise(candidate.Request) != normalise(reqBody) {
+			continue
+		}
+		r.used[i] = true
+		r.last[key] = candidate
+		interaction, found = candidate, true
+		break
+	}
+	if !found {
+		return nil, fmt.Errorf("replay: no recorded interaction for %s %s with request %s", service, operation, reqBody)
+	}
+
+	contentType := "application/x-amz-json-1.1"
+	if strings.HasPrefix(strings.TrimSpace(interaction.Response), "<") {
+		contentType = "text/xml"
+	}
+
+	return &http.Response{
+		StatusCode:    interaction.Status,
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		Header:        http.Header{"Content-Type": []string{contentType}, "X-Amzn-Requestid": []string{"replay"}},
+		Body:          io.NopCloser(strings.NewReader(interaction.Response)),
+		ContentLength: int64(len(interaction.Response)),
+		Request:       req,
+	}, nil
+}
+
+// Returns the interactions that haven't been replayed, in fixture order
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.fixture.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// Reports whether an operation only reads, going by AWS naming conventions
+func readOnly(operation string) bool {
+	for _, prefix := range []string{"Get", "List", "Describe", "BatchGet", "Filter"} {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads a body and replaces it with a copy, so it can still be sent or read
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return "", err
+	}
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+
+	return string(data), nil
+}
+
+// Returns the service and operation of a request. JSON protocol services
+// (e.g. CodePipeline) name the operation in the X-Amz-Target header, and query
+// protocol services (e.g. STS) in the Action form field.
+func describe(req *http.Request, body string) (string, string) {
+	service := strings.SplitN(req.URL.Hostname(), ".", 2)[0]
+
+	if target := req.Header.Get("X-Amz-Target"); target != "" {
+		if i := strings.LastIndex(target, "."); i >= 0 {
+			return service, target[i+1:]
+		}
+		return service, target
+	}
+
+	if values, err := url.ParseQuery(body); err == nil && values.Get("Action") != "" {
+		return service, values.Get("Action")
+	}
+
+	return service, req.Method + " " + req.URL.Path
+}
+
+// Normalises JSON bodies so that fixtures can be formatted freely. Idempotency
+// tokens are generated randomly by the SDK, so they are ignored.
+func normalise(body string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	if object, ok := v.(map[string]interface{}); ok {
+		delete(object, "clientRequestToken")
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
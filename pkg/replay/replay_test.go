@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newRequest(t *testing.T, target string, body string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "https://codepipeline.us-east-1.amazonaws.com/", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Amz-Target", target)
+
+	return req
+}
+
+func responseBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReplayer(t *testing.T) {
+	r := NewReplayer(&Fixture{Interactions: []Interaction{
+		{Service: "codepipeline", Operation: "GetPipelineExecution", Request: `{"pipelineName": "a"}`, Status: 200, Response: "first"},
+		{Service: "codepipeline", Operation: "GetPipelineExecution", Request: `{"pipelineName": "a"}`, Status: 200, Response: "second"},
+		{Service: "codepipeline", Operation: "StartPipelineExecution", Request: `{"name":"a"}`, Status: 200, Response: "started"},
+	}})
+
+	// Matching interactions are replayed in order, then the last one repeats
+	for _, want := range []string{"first", "second", "second"} {
+		resp, err := r.Do(newRequest(t, "CodePipeline_20150709.GetPipelineExecution", `{"pipelineName":"a"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := responseBody(t, resp); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	// Idempotency tokens are ignored when matching
+	resp, err := r.Do(newRequest(t, "CodePipeline_20150709.StartPipelineExecution", `{"clientRequestToken":"random","name":"a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := responseBody(t, resp); got != "started" {
+		t.Errorf("got %q, want %q", got, "started")
+	}
+
+	// Calls that change something are only replayed once
+	if _, err := r.Do(newRequest(t, "CodePipeline_20150709.StartPipelineExecution", `{"name":"a"}`)); err == nil {
+		t.Error("expected an error for a repeated StartPipelineExecution")
+	}
+
+	if _, err := r.Do(newRequest(t, "CodePipeline_20150709.GetPipelineExecution", `{"pipelineName":"b"}`)); err == nil {
+		t.Error("expected an error for a request that was not recorded")
+	}
+}
+
+func TestReplayerUnused(t *testing.T) {
+	r := NewReplayer(&Fixture{Interactions: []Interaction{
+		{Service: "codepipeline", Operation: "GetPipelineState", Request: `{"name":"a"}`, Status: 200, Response: "state"},
+		{Service: "codepipeline", Operation: "PutApprovalResult", Request: `{"pipelineName":"a"}`, Status: 200, Response: "approved"},
+	}})
+
+	if _, err := r.Do(newRequest(t, "CodePipeline_20150709.GetPipelineState", `{"name":"a"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	unused := r.Unused()
+	if len(unused) != 1 || unused[0].Operation != "PutApprovalResult" {
+		t.Errorf("got unused %+v, want only PutApprovalResult", unused)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://sts.us-east-1.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, operation := describe(req, "Action=GetCallerIdentity&Version=2011-06-15")
+	if service != "sts" || operation != "GetCallerIdentity" {
+		t.Errorf("got %s %s, want sts GetCallerIdentity", service, operation)
+	}
+}